@@ -3,29 +3,42 @@ package assert
 import (
 	"bytes"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
 )
 
-//Stolen from the go testing package: https://golang.org/src/testing/testing.go
-func decorate(message string) string {
-	_, file, line, ok := runtime.Caller(3)
-
-	if ok {
-		// Truncate file name at last file name separator
-		if index := strings.LastIndex(file, "/"); index >= 0 {
-			file = file[index+1:]
-		} else if index = strings.LastIndex(file, "\\"); index >= 0 {
-			file = file[index+1:]
+// Returns the file and line of the code which called into this package, including calls from its own tests
+func callerLocation() (file string, line int) {
+	_, assertFile, _, _ := runtime.Caller(0)
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	for {
+		frame, more := frames.Next()
+
+		if filepath.Dir(frame.File) != filepath.Dir(assertFile) || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File, frame.Line
+		}
+
+		if !more {
+			return "???", 1
 		}
-	} else {
-		file = "???"
-		line = 1
 	}
+}
 
-	//gotest renames _test.go files to _testx.go files, we want to show the original file name
-	file = strings.Replace(file, "x.go", ".go", 1)
+//Stolen from the go testing package: https://golang.org/src/testing/testing.go
+func decorate(message string) string {
+	file, line := callerLocation()
+
+	// Truncate file name at last file name separator
+	if index := strings.LastIndex(file, "/"); index >= 0 {
+		file = file[index+1:]
+	} else if index = strings.LastIndex(file, "\\"); index >= 0 {
+		file = file[index+1:]
+	}
 
 	buf := new(bytes.Buffer)
 	// Every line is indented at least one tab.
@@ -104,44 +117,42 @@ func areEqualReferences(x, y interface{}) bool {
 	return reflect.Indirect(reflect.ValueOf(x)) == reflect.Indirect(reflect.ValueOf(y))
 }
 
+// Fails unless the value matches
+func (e AssertValue) to(matcher Matcher) {
+	Expect(e.value).To(matcher)
+}
+
+// Fails if the value matches
+func (e AssertValue) notTo(matcher Matcher) {
+	Expect(e.value).NotTo(matcher)
+}
+
 func (v AssertValue) IsNil() {
-	if !areEqualValues(v.value, nil) {
-		message := fmt.Sprintf("Expected %#v to be nil.", v.value)
-		fail(message)
-	}
+	v.to(BeNil())
 }
 
 func (v AssertValue) IsNotNil() {
-	if areEqualValues(v.value, nil) {
-		message := fmt.Sprintf("Expected %#v to not be nil.", v.value)
-		fail(message)
-	}
+	v.notTo(BeNil())
 }
 
 func (e AssertValue) IsEqualTo(expected interface{}) {
-	if !areEqualValues(e.value, expected) {
-		message := fmt.Sprintf("Expected %#v to be equal to %#v.", expected, e.value)
-		fail(message)
-	}
+	e.to(Equal(expected))
 }
 
 func (e AssertValue) IsNotEqualTo(expected interface{}) {
-	if areEqualValues(e.value, expected) {
-		message := fmt.Sprintf("Expected %#v to not be equal to %#v.", expected, e.value)
-		fail(message)
-	}
+	e.notTo(Equal(expected))
 }
 
 func (e AssertValue) Is(expected interface{}) {
 	if !areEqualReferences(e.value, expected) {
-		message := fmt.Sprintf("Expected %#v to be the same object as %#v", expected, e.value)
+		message := fmt.Sprintf("Expected %s to be the same object as %s.", formatValue(e.value), formatValue(expected))
 		fail(message)
 	}
 }
 
 func (e AssertValue) IsNot(expected interface{}) {
 	if areEqualReferences(e.value, expected) {
-		message := fmt.Sprintf("Expected %#v to not be the same object as %#v", expected, e.value)
+		message := fmt.Sprintf("Expected %s to not be the same object as %s.", formatValue(e.value), formatValue(expected))
 		fail(message)
 	}
 }
@@ -162,37 +173,9 @@ func panics(f func()) bool {
 }
 
 func (e AssertValue) Panics() {
-	v := reflect.ValueOf(e.value)
-	t := v.Type()
-	if t.Kind() == reflect.Func {
-		if t.NumIn() != 0 {
-			fail("Cannot assert that function accepting arguments panics.")
-		}
-
-		f, _ := e.value.(func())
-
-		if !panics(f) {
-			fail("Expected function to panic but it did not.")
-		}
-	} else {
-		fail("Cannot assert that non function object panics.")
-	}
+	e.to(Panic())
 }
 
 func (e AssertValue) DoesNotPanic() {
-	v := reflect.ValueOf(e.value)
-	t := v.Type()
-	if t.Kind() == reflect.Func {
-		if t.NumIn() != 0 {
-			fail("Cannot assert that function accepting arguments does not panic.")
-		}
-
-		f, _ := e.value.(func())
-
-		if panics(f) {
-			fail("Expected function not to panic but it did.")
-		}
-	} else {
-		fail("Cannot assert that non function object does not panic.")
-	}
+	e.notTo(Panic())
 }
@@ -1,6 +1,13 @@
 package assert
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
 	. "github.com/claassen/gotest"
 )
 
@@ -9,6 +16,53 @@ type TestObj struct {
 	s string
 }
 
+type TestNode struct {
+	Name string
+	Next *TestNode
+}
+
+type TestTree struct {
+	Value       int
+	Left, Right *TestTree
+}
+
+//Matcher which only matches the first time it is used
+type TestFlakyMatcher struct {
+	calls int
+}
+
+func (m *TestFlakyMatcher) Match(actual interface{}) bool {
+	m.calls++
+	return m.calls == 1
+}
+
+func (m *TestFlakyMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %v to match after %d calls.", actual, m.calls)
+}
+
+func (m *TestFlakyMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %v to not match after %d calls.", actual, m.calls)
+}
+
+//Returns the message of the failed assertion in f
+func failureMessage(f func()) (message string) {
+	defer func() {
+		message = fmt.Sprint(recover())
+	}()
+
+	f()
+
+	return ""
+}
+
+func assertContains(message string, parts ...string) {
+	for _, part := range parts {
+		if !strings.Contains(message, part) {
+			Fail(fmt.Sprintf("Expected failure message to contain %q:\n%s", part, message))
+		}
+	}
+}
+
 func Test() {
 
 	Describe("When using IsNil and IsNotNil", func() {
@@ -691,4 +745,559 @@ func Test() {
 			}).Panics()
 		})
 	})
+
+	Describe("When an IsEqualTo assertion fails", func() {
+
+		It("labels the expected and actual values", func() {
+			message := failureMessage(func() {
+				AssertThat(4).IsEqualTo(5)
+			})
+
+			assertContains(message, "expected: 5", "actual:   4")
+		})
+
+		It("shows the paths of differing fields", func() {
+			message := failureMessage(func() {
+				AssertThat(TestObj{i: 1, s: "abc"}).IsEqualTo(TestObj{i: 1, s: "xyz"})
+			})
+
+			assertContains(message, `.s: expected "xyz", actual "abc"`)
+			AssertThat(strings.Contains(message, ".i:")).IsEqualTo(false)
+		})
+
+		It("shows missing and unexpected map keys", func() {
+			message := failureMessage(func() {
+				AssertThat(map[string]int{"a": 1, "c": 3}).IsEqualTo(map[string]int{"a": 2, "b": 2})
+			})
+
+			assertContains(message,
+				`["a"]: expected 2, actual 1`,
+				`["b"]: missing from actual, expected 2`,
+				`["c"]: unexpected in actual, got 3`)
+		})
+
+		It("shows changed, missing and unexpected slice elements", func() {
+			message := failureMessage(func() {
+				AssertThat([]int{1, 5}).IsEqualTo([]int{1, 2, 3})
+			})
+
+			assertContains(message,
+				"value: expected length 3, actual length 2",
+				"[1]: expected 2, actual 5",
+				"[2]: missing from actual, expected 3")
+		})
+
+		It("shows the changed lines of multi-line strings", func() {
+			message := failureMessage(func() {
+				AssertThat("one\ntwo\nthree").IsEqualTo("one\n2\nthree")
+			})
+
+			assertContains(message, "- 2", "+ two", "  one", "  three")
+		})
+
+		It("follows pointers and stops at cycles", func() {
+			expected := &TestNode{Name: "a"}
+			expected.Next = expected
+			actual := &TestNode{Name: "b"}
+			actual.Next = actual
+
+			message := failureMessage(func() {
+				AssertThat(actual).IsEqualTo(expected)
+			})
+
+			assertContains(message, `.Name: expected "a", actual "b"`, "<cycle>")
+		})
+
+		It("shows values shared by several pointers once", func() {
+			expected, actual := &TestTree{Value: 1}, &TestTree{Value: 2}
+
+			//Formatting every path through the trees would take hours
+			for i := 0; i < 30; i++ {
+				expected = &TestTree{Left: expected, Right: expected}
+				actual = &TestTree{Left: actual, Right: actual}
+			}
+
+			message := failureMessage(func() {
+				AssertThat(actual).IsEqualTo(expected)
+			})
+
+			assertContains(message, ".Left.Left.Left")
+			AssertThat(len(message) < 2000).IsEqualTo(true)
+
+			shared := &TestTree{Value: 1}
+			message = failureMessage(func() {
+				AssertThat(&TestTree{Left: shared, Right: shared}).IsNil()
+			})
+
+			assertContains(message, "Left:&", "Value:1", "Right:<already shown>}")
+		})
+
+		It("truncates large values", func() {
+			message := failureMessage(func() {
+				AssertThat(strings.Repeat("a", 1000)).IsEqualTo(strings.Repeat("b", 1000))
+			})
+
+			assertContains(message, "more characters)")
+			AssertThat(len(message) < 1000).IsEqualTo(true)
+		})
+
+		It("limits the number of differences shown", func() {
+			message := failureMessage(func() {
+				AssertThat(make([]int, 30)).IsEqualTo(make([]int, 0, 30)[:0])
+			})
+
+			assertContains(message, "more differences")
+		})
+	})
+
+	Describe("When using Expect with matchers", func() {
+
+		It("nil matches BeNil", func() {
+			Expect(nil).To(BeNil())
+			Expect(5).NotTo(BeNil())
+		})
+
+		It("equal values match Equal", func() {
+			Expect(TestObj{i: 1, s: "abc"}).To(Equal(TestObj{i: 1, s: "abc"}))
+			Expect(1).NotTo(Equal(2))
+		})
+
+		It("panicking functions match Panic", func() {
+			Expect(func() { panic("oops") }).To(Panic())
+			Expect(func() {}).NotTo(Panic())
+		})
+
+		It("functions returning values can be checked with Panic", func() {
+			Expect(func() int { panic("oops") }).To(Panic())
+		})
+
+		It("cannot check whether a non function panics", func() {
+			AssertThat(func() {
+				Expect(true).To(Panic())
+			}).Panics()
+		})
+
+		It("gives the same messages as AssertThat", func() {
+			//Each pair is on one line as the messages include the line of the assertion
+			AssertThat(failureMessage(func() { Expect(4).To(Equal(5)) })).IsEqualTo(failureMessage(func() { AssertThat(4).IsEqualTo(5) }))
+			AssertThat(failureMessage(func() { Expect(4).NotTo(Equal(4)) })).IsEqualTo(failureMessage(func() { AssertThat(4).IsNotEqualTo(4) }))
+			AssertThat(failureMessage(func() { Expect(4).To(BeNil()) })).IsEqualTo(failureMessage(func() { AssertThat(4).IsNil() }))
+			AssertThat(failureMessage(func() { Expect(func() {}).To(Panic()) })).IsEqualTo(failureMessage(func() { AssertThat(func() {}).Panics() }))
+		})
+
+		It("And matches when all matchers match", func() {
+			Expect(5).To(And(Not(BeNil()), Equal(5)))
+			Expect(5).To(SatisfyAll(Equal(5), Not(Equal(6))))
+			Expect(5).NotTo(And(Equal(5), Equal(6)))
+		})
+
+		It("And fails with the message of the first matcher which does not match", func() {
+			message := failureMessage(func() {
+				Expect(5).To(And(Not(BeNil()), Equal(6), BeNil()))
+			})
+
+			assertContains(message, "expected: 6", "actual:   5")
+			AssertThat(strings.Contains(message, "to be nil")).IsEqualTo(false)
+		})
+
+		It("Or matches when any matcher matches", func() {
+			Expect(5).To(Or(BeNil(), Equal(5)))
+			Expect(5).NotTo(Or(BeNil(), Equal(6)))
+		})
+
+		It("Or fails with the messages of all matchers", func() {
+			message := failureMessage(func() {
+				Expect(5).To(Or(BeNil(), Equal(6)))
+			})
+
+			assertContains(message, "none held", "Expected 5 to be nil.", "expected: 6")
+		})
+
+		It("Not inverts a matcher and its messages", func() {
+			Expect(5).To(Not(BeNil()))
+			Expect(nil).NotTo(Not(BeNil()))
+
+			assertContains(failureMessage(func() { Expect(nil).To(Not(BeNil())) }), "Expected nil to not be nil.")
+		})
+
+		It("WithTransform matches the transformed value", func() {
+			length := func(s string) int { return len(s) }
+
+			Expect("abc").To(WithTransform(length, Equal(3)))
+			Expect("abc").NotTo(WithTransform(length, Equal(4)))
+			assertContains(failureMessage(func() { Expect("abc").To(WithTransform(length, Equal(4))) }), "actual:   3")
+		})
+
+		It("checks the value once when combining matchers", func() {
+			calls := 0
+			panics := func() { calls++; panic("oops") }
+
+			AssertThat(failureMessage(func() { Expect(panics).To(And(Panic(), BeNil())) })).IsNotEqualTo("")
+			AssertThat(calls).IsEqualTo(1)
+
+			transforms := 0
+			length := func(s string) int { transforms++; return len(s) }
+
+			failureMessage(func() { Expect("abc").To(WithTransform(length, Equal(4))) })
+			AssertThat(transforms).IsEqualTo(1)
+		})
+
+		It("reports the result of the match when a matcher gives a different answer the second time", func() {
+			assertContains(failureMessage(func() { Expect(0).To(Not(And(&TestFlakyMatcher{}))) }), "Expected 0 to not match after 1 calls.")
+			assertContains(failureMessage(func() { Expect(0).NotTo(Or(&TestFlakyMatcher{})) }), "Expected 0 to not match after 1 calls.")
+			assertContains(failureMessage(func() { Expect([]int{1, 2}).NotTo(AnyElement(&TestFlakyMatcher{})) }),
+				"but [0] did", "Expected 1 to not match after 1 calls.")
+		})
+
+		It("WithTransform requires a function taking one argument and returning one value", func() {
+			AssertThat(func() {
+				WithTransform(func() {}, Equal(3))
+			}).Panics()
+		})
+
+		It("WithTransform cannot transform values of the wrong type", func() {
+			AssertThat(func() {
+				Expect(5).To(WithTransform(func(s string) int { return len(s) }, Equal(3)))
+			}).Panics()
+		})
+	})
+
+	Describe("When using collection assertions", func() {
+
+		It("HasLen checks the length of strings, slices, arrays, maps and channels", func() {
+			ch := make(chan int, 3)
+			ch <- 1
+
+			AssertThat("abc").HasLen(3)
+			AssertThat([]int{1, 2}).HasLen(2)
+			AssertThat([3]int{}).HasLen(3)
+			AssertThat(map[string]int{"a": 1}).HasLen(1)
+			AssertThat(ch).HasLen(1)
+			assertContains(failureMessage(func() { AssertThat([]int{1, 2}).HasLen(3) }), "to have length 3, but it has length 2")
+		})
+
+		It("cannot check the length of a number", func() {
+			AssertThat(func() {
+				AssertThat(5).HasLen(1)
+			}).Panics()
+		})
+
+		It("IsEmpty and IsNotEmpty check for empty collections", func() {
+			var nilSlice []int
+
+			AssertThat(nilSlice).IsEmpty()
+			AssertThat(map[int]int{}).IsEmpty()
+			AssertThat([]int{1}).IsNotEmpty()
+			AssertThat(func() {
+				AssertThat([]int{1}).IsEmpty()
+			}).Panics()
+		})
+
+		It("Contains finds equal elements in slices, arrays, maps and channels", func() {
+			ch := make(chan string, 2)
+			ch <- "a"
+			ch <- "b"
+
+			AssertThat([]int{1, 2, 3}).Contains(2)
+			AssertThat([2]string{"a", "b"}).Contains("b")
+			AssertThat(map[string]int{"a": 1}).Contains(1)
+			AssertThat(ch).Contains("b")
+			AssertThat([]TestObj{{i: 1, s: "abc"}}).Contains(TestObj{i: 1, s: "abc"})
+			AssertThat([]int{1, 2, 3}).DoesNotContain(4)
+		})
+
+		It("Contains compares elements as IsEqualTo does", func() {
+			AssertThat([]int64{1, 2}).Contains(2)
+			AssertThat([]*TestObj{nil}).Contains(nil)
+		})
+
+		It("Contains accepts matchers", func() {
+			AssertThat([]int{1, 2, 3}).Contains(Not(Equal(1)))
+		})
+
+		It("Contains leaves the elements in a channel", func() {
+			ch := make(chan int, 2)
+			ch <- 1
+			ch <- 2
+
+			AssertThat(ch).Contains(2)
+			AssertThat(<-ch).IsEqualTo(1)
+			AssertThat(<-ch).IsEqualTo(2)
+		})
+
+		It("cannot get the elements of a receive-only channel without emptying it", func() {
+			ch := make(chan int, 2)
+			ch <- 1
+			ch <- 2
+			var ro <-chan int = ch
+
+			assertContains(failureMessage(func() { AssertThat(ro).ContainsAll(1, 5) }), "Cannot get the elements of receive-only channel")
+			AssertThat(len(ch)).IsEqualTo(2)
+		})
+
+		It("reads the elements of a closed channel once for the result and the message", func() {
+			ch := make(chan int, 2)
+			ch <- 1
+			ch <- 2
+			close(ch)
+
+			message := failureMessage(func() { AssertThat(ch).ContainsExactlyInAnyOrder(1) })
+			assertContains(message, "unexpected: [2]")
+			AssertThat(strings.Contains(message, "missing")).IsEqualTo(false)
+		})
+
+		It("Contains names the element when it should not contain it", func() {
+			assertContains(failureMessage(func() { AssertThat([]int{1, 2}).DoesNotContain(2) }), "but [1] is 2")
+		})
+
+		It("ContainsAll names the missing elements", func() {
+			AssertThat([]int{1, 2, 3}).ContainsAll(3, 1)
+			assertContains(failureMessage(func() { AssertThat([]int{1, 2, 3}).ContainsAll(3, 4, 5) }), "it is missing [4, 5]")
+		})
+
+		It("ContainsExactlyInAnyOrder names the missing and unexpected elements", func() {
+			AssertThat([]int{1, 2, 2}).ContainsExactlyInAnyOrder(2, 1, 2)
+			AssertThat(map[string]int{"a": 1, "b": 2}).ContainsExactlyInAnyOrder(2, 1)
+
+			message := failureMessage(func() { AssertThat([]int{1, 2, 2}).ContainsExactlyInAnyOrder(1, 2, 3) })
+			assertContains(message, "missing:    [3]", "unexpected: [2]")
+		})
+
+		It("ContainsExactlyInAnyOrder pairs elements with matchers which match several of them", func() {
+			AssertThat([]int{1, 2}).ContainsExactlyInAnyOrder(Not(BeNil()), 1)
+		})
+
+		It("ContainsExactlyInAnyOrder describes matchers the way they were created", func() {
+			message := failureMessage(func() { AssertThat([]int{-1}).ContainsExactlyInAnyOrder(BeGreaterThan(0), Or(BeNil(), Equal(7))) })
+			assertContains(message, `to contain exactly [BeGreaterThan(0), Or(BeNil(), Equal(7))] in any order`,
+				`missing:    [BeGreaterThan(0), Or(BeNil(), Equal(7))]`, "unexpected: [-1]")
+		})
+
+		It("ContainsInOrder finds elements in order with other elements between them", func() {
+			AssertThat([]int{1, 2, 3, 4}).ContainsInOrder(1, 3, 4)
+
+			message := failureMessage(func() { AssertThat([]int{1, 2, 3, 4}).ContainsInOrder(1, 4, 3) })
+			assertContains(message, "but it does not contain 3 after [1, 4]")
+		})
+
+		It("HasKey and HasKeyWithValue check map entries", func() {
+			m := map[string]int{"a": 1, "b": 2}
+
+			AssertThat(m).HasKey("a")
+			AssertThat(m).HasKeyWithValue("b", 2)
+			AssertThat(map[int64]string{1: "x"}).HasKeyWithValue(1, "x")
+			assertContains(failureMessage(func() { AssertThat(m).HasKey("c") }), `to have key "c"`)
+			assertContains(failureMessage(func() { AssertThat(m).HasKeyWithValue("b", 3) }), "but its value is 2")
+			assertContains(failureMessage(func() { AssertThat(m).HasKeyWithValue("c", 3) }), "but it has no such key")
+		})
+
+		It("cannot check the keys of a slice", func() {
+			AssertThat(func() {
+				AssertThat([]int{1}).HasKey(0)
+			}).Panics()
+		})
+
+		It("AllSatisfy checks every element", func() {
+			AssertThat([]int{1, 1}).AllSatisfy(Equal(1))
+			AssertThat([]int{}).AllSatisfy(Equal(1))
+
+			message := failureMessage(func() { AssertThat(map[string]int{"a": 1, "b": 2}).AllSatisfy(Equal(1)) })
+			assertContains(message, `but ["b"] did not`, "expected: 1", "actual:   2")
+		})
+
+		It("AnySatisfy checks for a matching element", func() {
+			AssertThat([]int{1, 2}).AnySatisfy(Equal(2))
+			assertContains(failureMessage(func() { AssertThat([]int{}).AnySatisfy(Equal(2)) }), "but it is empty")
+			assertContains(failureMessage(func() { AssertThat([]int{1}).AnySatisfy(Equal(2)) }), "but none did", "[0]: Expected values to be equal.")
+		})
+	})
+
+	Describe("When using string assertions", func() {
+
+		It("accepts strings, byte slices, errors and Stringers", func() {
+			AssertThat("abc").StartsWith("ab")
+			AssertThat([]byte("abc")).StartsWith("ab")
+			AssertThat(errors.New("abc")).StartsWith("ab")
+			AssertThat(&url.URL{Scheme: "https", Host: "example.com"}).StartsWith("https://")
+		})
+
+		It("cannot use a number as a string", func() {
+			AssertThat(func() {
+				AssertThat(5).StartsWith("5")
+			}).Panics()
+		})
+
+		It("StartsWith and EndsWith check prefixes and suffixes", func() {
+			AssertThat("gotest").StartsWith("go")
+			AssertThat("gotest").EndsWith("test")
+			assertContains(failureMessage(func() { AssertThat("gotest").StartsWith("test") }), `Expected "gotest" to start with "test".`)
+			assertContains(failureMessage(func() { AssertThat("gotest").EndsWith("go") }), `Expected "gotest" to end with "go".`)
+		})
+
+		It("ContainsSubstring finds substrings", func() {
+			AssertThat("one two three").ContainsSubstring("two")
+			assertContains(failureMessage(func() { AssertThat("one").ContainsSubstring("two") }), `Expected "one" to contain "two".`)
+			assertContains(failureMessage(func() { Expect("one\ntwo").NotTo(ContainSubstring("wo")) }), "but it does at line 2, column 2")
+		})
+
+		It("MatchesRegex finds matches of a regular expression", func() {
+			AssertThat("version 1.2.3").MatchesRegex(`\d+\.\d+\.\d+`)
+			assertContains(failureMessage(func() { AssertThat("version").MatchesRegex(`\d+`) }), `to match regular expression "\\d+"`)
+			assertContains(failureMessage(func() { Expect("v12").NotTo(MatchRegex(`\d+`)) }), `but "12" at line 1, column 2 does`)
+		})
+
+		It("cannot match an invalid regular expression", func() {
+			AssertThat(func() {
+				AssertThat("abc").MatchesRegex("(")
+			}).Panics()
+		})
+
+		It("EqualsIgnoringCase compares letters regardless of case", func() {
+			AssertThat("GoTest").EqualsIgnoringCase("gotest")
+			assertContains(failureMessage(func() { AssertThat("GoTest").EqualsIgnoringCase("gotests") }), "ignoring case")
+		})
+
+		It("EqualsIgnoringWhitespace removes all whitespace before comparing", func() {
+			AssertThat(" a b\n\tc ").EqualsIgnoringWhitespace("abc")
+			AssertThat("abc").EqualsIgnoringWhitespace("a b c")
+			AssertThat(func() {
+				AssertThat("a b").EqualsIgnoringWhitespace("abc")
+			}).Panics()
+		})
+
+		It("HasLineCount counts lines without an extra line for a final line break", func() {
+			AssertThat("").HasLineCount(0)
+			AssertThat("one").HasLineCount(1)
+			AssertThat("one\ntwo\n").HasLineCount(2)
+			assertContains(failureMessage(func() { AssertThat("one\ntwo").HasLineCount(3) }), "to have 3 lines, but it has 2")
+		})
+
+		It("shows the line and column of the first difference between long strings", func() {
+			expected := "first line\nsecond line is " + strings.Repeat("long ", 20) + "and ends here"
+			actual := "first line\nSECOND LINE IS " + strings.Repeat("long ", 20) + "and ends there"
+
+			message := failureMessage(func() { AssertThat(actual).EqualsIgnoringCase(expected) })
+
+			assertContains(message, "first difference at line 2, column 125", `expected: ..."`, `actual:   ..."`, "^")
+		})
+
+		It("shows where long strings fail to end with a suffix, contain a substring, match a regex or have enough lines", func() {
+			long := strings.Repeat("log line\n", 50)
+
+			assertContains(failureMessage(func() { AssertThat(long + "status: failed\n").EndsWith("status: passed\n") }),
+				"first difference at line 1, column 9 of expected and line 51, column 9 of actual", `actual:   "status: failed"`)
+			assertContains(failureMessage(func() { AssertThat(long + "error: disk full").ContainsSubstring("error: disk quota") }),
+				"its first 12 characters are found at line 51, column 1", `actual:   "error: disk full"`)
+			assertContains(failureMessage(func() { AssertThat(long + "version: 1.2.x").MatchesRegex(`version: \d+\.\d+\.\d+`) }),
+				"its longest start which matches ends at line 51, column 14", `actual:   "version: 1.2.x"`)
+			assertContains(failureMessage(func() { AssertThat(long).HasLineCount(3) }), "line 4 is the first extra line")
+			assertContains(failureMessage(func() { AssertThat("a\nb\n").HasLineCount(3) }), "it ends at line 2, column 2")
+		})
+
+		It("shows the position of the first difference between long strings which are not equal", func() {
+			long := strings.Repeat("x", 100)
+
+			assertContains(failureMessage(func() { AssertThat(long + "a").IsEqualTo(long + "b") }), "first difference at line 1, column 101")
+		})
+	})
+
+	Describe("When using numeric assertions", func() {
+
+		It("compares numbers of different kinds without casts", func() {
+			AssertThat(5).IsGreaterThan(4.5)
+			AssertThat(uint8(5)).IsGreaterThan(-1)
+			AssertThat(int64(-1)).IsLessThan(uint64(math.MaxUint64))
+			AssertThat(float32(1.5)).IsLessThan(2)
+			AssertThat(3).IsGreaterThanOrEqualTo(uint(3))
+			AssertThat(3.0).IsLessThanOrEqualTo(3)
+		})
+
+		It("compares large integers exactly", func() {
+			AssertThat(int64(math.MaxInt64)).IsGreaterThan(int64(math.MaxInt64 - 1))
+			AssertThat(uint64(math.MaxUint64)).IsGreaterThan(uint64(math.MaxUint64 - 1))
+		})
+
+		It("compares durations and times", func() {
+			now := time.Now()
+
+			AssertThat(2 * time.Second).IsGreaterThan(time.Second)
+			AssertThat(time.Second).IsPositive()
+			AssertThat(now).IsLessThan(now.Add(time.Millisecond))
+			AssertThat(now).IsBetween(now.Add(-time.Hour), now)
+		})
+
+		It("names the values which do not compare as expected", func() {
+			assertContains(failureMessage(func() { AssertThat(3).IsGreaterThan(4) }), "Expected 3 to be greater than 4.")
+			assertContains(failureMessage(func() { AssertThat(time.Second).IsLessThan(time.Millisecond) }), "Expected 1s to be less than 1ms.")
+			assertContains(failureMessage(func() { AssertThat(0).IsPositive() }), "Expected 0 to be positive.")
+			assertContains(failureMessage(func() { AssertThat(11).IsBetween(1, 10) }), "Expected 11 to be between 1 and 10.")
+		})
+
+		It("IsBetween includes both ends", func() {
+			AssertThat(1).IsBetween(1, 10)
+			AssertThat(10).IsBetween(1, 10)
+		})
+
+		It("IsPositive and IsNegative check the sign", func() {
+			AssertThat(-0.5).IsNegative()
+			AssertThat(func() {
+				AssertThat(uint(0)).IsNegative()
+			}).Panics()
+		})
+
+		It("NaN is not ordered", func() {
+			AssertThat(func() {
+				AssertThat(math.NaN()).IsGreaterThan(0)
+			}).Panics()
+			AssertThat(func() {
+				AssertThat(math.NaN()).IsLessThanOrEqualTo(0)
+			}).Panics()
+		})
+
+		It("cannot compare values which are not numbers", func() {
+			AssertThat(func() {
+				AssertThat("5").IsGreaterThan(4)
+			}).Panics()
+			AssertThat(func() {
+				AssertThat(time.Now()).IsGreaterThan(4)
+			}).Panics()
+		})
+
+		It("IsCloseTo allows a difference of up to epsilon", func() {
+			now := time.Now()
+
+			AssertThat(0.1 + 0.2).IsCloseTo(0.3, 1e-9)
+			AssertThat(98).IsCloseTo(100, 2)
+			AssertThat(now.Add(time.Millisecond)).IsCloseTo(now, time.Second)
+			assertContains(failureMessage(func() { AssertThat(1.5).IsCloseTo(1, 0.1) }), "Expected 1.5 to be within 0.1 of 1, but it differs by 0.5.")
+			assertContains(failureMessage(func() { AssertThat(now.Add(time.Minute)).IsCloseTo(now, time.Second) }), "but it differs by 1m0s.")
+		})
+
+		It("IsCloseTo finds the exact difference between large integers", func() {
+			AssertThat(int64(math.MaxInt64)).IsCloseTo(int64(math.MaxInt64-1), 1)
+			AssertThat(uint64(math.MaxUint64)).IsCloseTo(int64(-1), uint64(math.MaxUint64))
+			assertContains(failureMessage(func() { AssertThat(int64(1 << 62)).IsCloseTo(int64(1<<62+2), 1) }), "but it differs by 2.")
+			assertContains(failureMessage(func() { AssertThat(int64(math.MinInt64)).IsCloseTo(int64(math.MaxInt64), 1) }), "but it differs by 18446744073709551615.")
+		})
+
+		It("IsWithinPercent allows a difference relative to the expected value", func() {
+			AssertThat(105).IsWithinPercent(100, 5)
+			AssertThat(0).IsWithinPercent(0, 1)
+			AssertThat(900 * time.Millisecond).IsWithinPercent(time.Second, 10)
+			assertContains(failureMessage(func() { AssertThat(120).IsWithinPercent(100, 5) }), "Expected 120 to be within 5% of 100, but it differs by 20%.")
+		})
+
+		It("checks for NaN and infinity", func() {
+			AssertThat(math.NaN()).IsNaN()
+			AssertThat(math.Inf(-1)).IsInf()
+			AssertThat(1.5).IsFinite()
+			AssertThat(7).IsFinite()
+			Expect(math.Inf(1)).NotTo(BeFinite())
+			assertContains(failureMessage(func() { AssertThat(1.5).IsNaN() }), "Expected 1.5 to be NaN.")
+		})
+
+		It("can be combined with other matchers", func() {
+			Expect([]int{2, 4, 6}).To(AllElements(And(BePositive(), BeLessThan(10))))
+		})
+	})
 }
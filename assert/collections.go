@@ -0,0 +1,599 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// An element of a collection along with where it is in the collection, [index] or [key]
+type element struct {
+	label string
+	value interface{}
+}
+
+// Returns the length of a string, slice, array, map or channel
+func lengthOf(actual interface{}) int {
+	v := reflect.ValueOf(actual)
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return v.Len()
+	}
+
+	fail(fmt.Sprintf("Cannot get the length of %s, a string, slice, array, map or channel is required.", formatValue(actual)))
+	return 0
+}
+
+// Returns the elements of a slice, array, map or channel. The elements of a map are its values in the order of
+// their keys. The elements of a channel are those in its buffer, which are received and then sent back, unless the
+// channel has been closed and they cannot be. Receive-only channels are not supported as their elements could not
+// be sent back.
+func elementsOf(actual interface{}) []element {
+	v := reflect.ValueOf(actual)
+	var elements []element
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elements = append(elements, element{fmt.Sprintf("[%d]", i), v.Index(i).Interface()})
+		}
+	case reflect.Map:
+		for _, key := range sortedKeys(v) {
+			elements = append(elements, element{fmt.Sprintf("[%s]", formatValue(key.Interface())), v.MapIndex(key).Interface()})
+		}
+	case reflect.Chan:
+		switch v.Type().ChanDir() {
+		case reflect.SendDir:
+			fail(fmt.Sprintf("Cannot get the elements of send-only channel %s.", formatValue(actual)))
+		case reflect.RecvDir:
+			fail(fmt.Sprintf("Cannot get the elements of receive-only channel %s, as they could not be sent back.", formatValue(actual)))
+		}
+
+		var received []reflect.Value
+		closed := false
+
+		for {
+			x, ok := v.TryRecv()
+
+			if !ok {
+				//A receive from a closed channel succeeds straight away with the zero value
+				closed = x.IsValid()
+				break
+			}
+
+			elements = append(elements, element{fmt.Sprintf("[%d]", len(received)), x.Interface()})
+			received = append(received, x)
+		}
+
+		if !closed {
+			for _, x := range received {
+				v.TrySend(x)
+			}
+		}
+	default:
+		fail(fmt.Sprintf("Cannot get the elements of %s, a slice, array, map or channel is required.", formatValue(actual)))
+	}
+
+	return elements
+}
+
+// The elements of a collection as read by Match. The failure messages are built from them rather than by reading
+// the collection again, as reading a channel receives its elements.
+type elementSnapshot struct {
+	elements []element
+	read     bool
+}
+
+// Reads the elements of a collection for Match
+func (s *elementSnapshot) readElements(actual interface{}) []element {
+	s.elements = elementsOf(actual)
+	s.read = true
+
+	return s.elements
+}
+
+// Returns the elements read by Match, reading them if Match was not called
+func (s *elementSnapshot) matchedElements(actual interface{}) []element {
+	if !s.read {
+		return s.readElements(actual)
+	}
+
+	return s.elements
+}
+
+// Reports whether an element matches an expected element, which is either a value it must be equal to or a Matcher
+func matchesElement(value, expected interface{}) bool {
+	if matcher, ok := expected.(Matcher); ok {
+		return matcher.Match(value)
+	}
+
+	return areEqualValues(value, expected)
+}
+
+// Formats a list of values for a failure message
+func formatValues(values []interface{}) string {
+	formatted := make([]string, len(values))
+
+	for i, value := range values {
+		formatted[i] = formatValue(value)
+	}
+
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
+
+// Returns the expected elements which are not matched by any of the elements
+func missingElements(elements []element, expected []interface{}) []interface{} {
+	var missing []interface{}
+
+	for _, e := range expected {
+		found := false
+
+		for _, el := range elements {
+			if matchesElement(el.value, e) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+
+	return missing
+}
+
+// Pairs each element with a different expected element it matches, pairing as many as possible. Returns the expected
+// elements and the elements which could not be paired.
+func pairElements(elements []element, expected []interface{}) (missing []interface{}, unexpected []interface{}) {
+	//Index of the element paired with each expected element, or -1
+	pairedWith := make([]int, len(expected))
+
+	for i := range pairedWith {
+		pairedWith[i] = -1
+	}
+
+	//Finds an expected element for an element, moving earlier pairs to other expected elements where needed
+	var pair func(el int, tried []bool) bool
+	pair = func(el int, tried []bool) bool {
+		for e := range expected {
+			if tried[e] || !matchesElement(elements[el].value, expected[e]) {
+				continue
+			}
+
+			tried[e] = true
+
+			if pairedWith[e] == -1 || pair(pairedWith[e], tried) {
+				pairedWith[e] = el
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for el := range elements {
+		if !pair(el, make([]bool, len(expected))) {
+			unexpected = append(unexpected, elements[el].value)
+		}
+	}
+
+	for e, el := range pairedWith {
+		if el == -1 {
+			missing = append(missing, expected[e])
+		}
+	}
+
+	return missing, unexpected
+}
+
+type lenMatcher struct {
+	length int
+}
+
+// HaveLen matches strings, slices, arrays, maps and channels of the given length
+func HaveLen(length int) Matcher {
+	return lenMatcher{length}
+}
+
+func (m lenMatcher) Match(actual interface{}) bool {
+	return lengthOf(actual) == m.length
+}
+
+func (m lenMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to have length %d, but it has length %d.", formatValue(actual), m.length, lengthOf(actual))
+}
+
+func (m lenMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not have length %d.", formatValue(actual), m.length)
+}
+
+func (m lenMatcher) String() string {
+	return describeCall("HaveLen", m.length)
+}
+
+type emptyMatcher struct{}
+
+// BeEmpty matches empty strings, slices, arrays, maps and channels
+func BeEmpty() Matcher {
+	return emptyMatcher{}
+}
+
+func (emptyMatcher) Match(actual interface{}) bool {
+	return lengthOf(actual) == 0
+}
+
+func (emptyMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be empty, but it has length %d.", formatValue(actual), lengthOf(actual))
+}
+
+func (emptyMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be empty.", formatValue(actual))
+}
+
+func (emptyMatcher) String() string {
+	return "BeEmpty()"
+}
+
+type containMatcher struct {
+	elementSnapshot
+	expected interface{}
+}
+
+// ContainElement matches collections with an element equal to the expected value, or matching it if it is a Matcher
+func ContainElement(expected interface{}) Matcher {
+	return &containMatcher{expected: expected}
+}
+
+func (m *containMatcher) Match(actual interface{}) bool {
+	return len(missingElements(m.readElements(actual), []interface{}{m.expected})) == 0
+}
+
+func (m *containMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to contain %s.", formatValue(actual), formatValue(m.expected))
+}
+
+func (m *containMatcher) NegatedFailureMessage(actual interface{}) string {
+	for _, el := range m.matchedElements(actual) {
+		if matchesElement(el.value, m.expected) {
+			return fmt.Sprintf("Expected %s to not contain %s, but %s is %s.", formatValue(actual), formatValue(m.expected),
+				el.label, formatValue(el.value))
+		}
+	}
+
+	return ""
+}
+
+func (m *containMatcher) String() string {
+	return describeCall("ContainElement", m.expected)
+}
+
+type containAllMatcher struct {
+	elementSnapshot
+	expected []interface{}
+}
+
+// ContainElements matches collections which contain all of the expected elements, in any order and along with any
+// other elements
+func ContainElements(expected ...interface{}) Matcher {
+	return &containAllMatcher{expected: expected}
+}
+
+func (m *containAllMatcher) Match(actual interface{}) bool {
+	return len(missingElements(m.readElements(actual), m.expected)) == 0
+}
+
+func (m *containAllMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to contain all of %s, but it is missing %s.", formatValue(actual),
+		formatValues(m.expected), formatValues(missingElements(m.matchedElements(actual), m.expected)))
+}
+
+func (m *containAllMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not contain all of %s.", formatValue(actual), formatValues(m.expected))
+}
+
+func (m *containAllMatcher) String() string {
+	return describeCall("ContainElements", m.expected...)
+}
+
+type consistOfMatcher struct {
+	elementSnapshot
+	expected []interface{}
+}
+
+// ConsistOf matches collections whose elements are exactly the expected elements in any order
+func ConsistOf(expected ...interface{}) Matcher {
+	return &consistOfMatcher{expected: expected}
+}
+
+func (m *consistOfMatcher) Match(actual interface{}) bool {
+	missing, unexpected := pairElements(m.readElements(actual), m.expected)
+	return len(missing) == 0 && len(unexpected) == 0
+}
+
+func (m *consistOfMatcher) FailureMessage(actual interface{}) string {
+	message := fmt.Sprintf("Expected %s to contain exactly %s in any order.", formatValue(actual), formatValues(m.expected))
+	missing, unexpected := pairElements(m.matchedElements(actual), m.expected)
+
+	if len(missing) > 0 {
+		message += "\nmissing:    " + formatValues(missing)
+	}
+
+	if len(unexpected) > 0 {
+		message += "\nunexpected: " + formatValues(unexpected)
+	}
+
+	return message
+}
+
+func (m *consistOfMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not contain exactly %s in any order.", formatValue(actual), formatValues(m.expected))
+}
+
+func (m *consistOfMatcher) String() string {
+	return describeCall("ConsistOf", m.expected...)
+}
+
+type containInOrderMatcher struct {
+	elementSnapshot
+	expected []interface{}
+}
+
+// ContainElementsInOrder matches collections which contain the expected elements in the same order, with any other
+// elements before, between or after them
+func ContainElementsInOrder(expected ...interface{}) Matcher {
+	return &containInOrderMatcher{expected: expected}
+}
+
+// Returns the number of expected elements found in order among the elements
+func (m *containInOrderMatcher) found(elements []element) int {
+	found := 0
+
+	for _, el := range elements {
+		if found < len(m.expected) && matchesElement(el.value, m.expected[found]) {
+			found++
+		}
+	}
+
+	return found
+}
+
+func (m *containInOrderMatcher) Match(actual interface{}) bool {
+	return m.found(m.readElements(actual)) == len(m.expected)
+}
+
+func (m *containInOrderMatcher) FailureMessage(actual interface{}) string {
+	message := fmt.Sprintf("Expected %s to contain %s in order, ", formatValue(actual), formatValues(m.expected))
+	found := m.found(m.matchedElements(actual))
+
+	if found == 0 {
+		return message + fmt.Sprintf("but it does not contain %s.", formatValue(m.expected[0]))
+	}
+
+	return message + fmt.Sprintf("but it does not contain %s after %s.", formatValue(m.expected[found]), formatValues(m.expected[:found]))
+}
+
+func (m *containInOrderMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not contain %s in order.", formatValue(actual), formatValues(m.expected))
+}
+
+func (m *containInOrderMatcher) String() string {
+	return describeCall("ContainElementsInOrder", m.expected...)
+}
+
+// Returns the key of a map equal to the expected key, which need not be of the same type
+func findKey(actual interface{}, key interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(actual)
+
+	if v.Kind() != reflect.Map {
+		fail(fmt.Sprintf("Cannot get the keys of %s, a map is required.", formatValue(actual)))
+	}
+
+	for _, k := range sortedKeys(v) {
+		if matchesElement(k.Interface(), key) {
+			return k, true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+type keyMatcher struct {
+	key interface{}
+}
+
+// HaveKey matches maps with a key equal to the expected key, or matching it if it is a Matcher
+func HaveKey(key interface{}) Matcher {
+	return keyMatcher{key}
+}
+
+func (m keyMatcher) Match(actual interface{}) bool {
+	_, found := findKey(actual, m.key)
+	return found
+}
+
+func (m keyMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to have key %s.", formatValue(actual), formatValue(m.key))
+}
+
+func (m keyMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not have key %s.", formatValue(actual), formatValue(m.key))
+}
+
+func (m keyMatcher) String() string {
+	return describeCall("HaveKey", m.key)
+}
+
+type keyWithValueMatcher struct {
+	key   interface{}
+	value interface{}
+}
+
+// HaveKeyWithValue matches maps with the expected key whose value is equal to the expected value. Either can be a
+// Matcher.
+func HaveKeyWithValue(key, value interface{}) Matcher {
+	return keyWithValueMatcher{key, value}
+}
+
+func (m keyWithValueMatcher) Match(actual interface{}) bool {
+	key, found := findKey(actual, m.key)
+	return found && matchesElement(reflect.ValueOf(actual).MapIndex(key).Interface(), m.value)
+}
+
+func (m keyWithValueMatcher) FailureMessage(actual interface{}) string {
+	message := fmt.Sprintf("Expected %s to have key %s with value %s, ", formatValue(actual), formatValue(m.key), formatValue(m.value))
+	key, found := findKey(actual, m.key)
+
+	if !found {
+		return message + "but it has no such key."
+	}
+
+	return message + fmt.Sprintf("but its value is %s.", formatValue(reflect.ValueOf(actual).MapIndex(key).Interface()))
+}
+
+func (m keyWithValueMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not have key %s with value %s.", formatValue(actual), formatValue(m.key), formatValue(m.value))
+}
+
+func (m keyWithValueMatcher) String() string {
+	return describeCall("HaveKeyWithValue", m.key, m.value)
+}
+
+type allElementsMatcher struct {
+	elementSnapshot
+	matcher Matcher
+	//First element which did not match, recorded by Match
+	failed element
+}
+
+// AllElements matches collections whose elements all match the matcher. Empty collections match.
+func AllElements(matcher Matcher) Matcher {
+	return &allElementsMatcher{matcher: matcher}
+}
+
+func (m *allElementsMatcher) Match(actual interface{}) bool {
+	for _, el := range m.readElements(actual) {
+		if !m.matcher.Match(el.value) {
+			m.failed = el
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *allElementsMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected every element of %s to match, but %s did not:\n  %s", formatValue(actual), m.failed.label,
+		indent(m.matcher.FailureMessage(m.failed.value)))
+}
+
+func (m *allElementsMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected some element of %s to not match, but all of them did.", formatValue(actual))
+}
+
+func (m *allElementsMatcher) String() string {
+	return describeCall("AllElements", m.matcher)
+}
+
+type anyElementMatcher struct {
+	elementSnapshot
+	matcher Matcher
+	//First element which matched, recorded by Match
+	matched element
+	//Failure messages of the elements which did not match, recorded by Match as each element is checked because
+	//the matcher may record what its messages report
+	failures []string
+}
+
+// AnyElement matches collections with at least one element which matches the matcher
+func AnyElement(matcher Matcher) Matcher {
+	return &anyElementMatcher{matcher: matcher}
+}
+
+func (m *anyElementMatcher) Match(actual interface{}) bool {
+	elements := m.readElements(actual)
+	m.failures = nil
+
+	for i, el := range elements {
+		if m.matcher.Match(el.value) {
+			m.matched = el
+			return true
+		}
+
+		if i < maxDifferences {
+			m.failures = append(m.failures, el.label+": "+m.matcher.FailureMessage(el.value))
+		}
+	}
+
+	if len(elements) > maxDifferences {
+		m.failures = append(m.failures, fmt.Sprintf("... and %d more elements", len(elements)-maxDifferences))
+	}
+
+	return false
+}
+
+func (m *anyElementMatcher) FailureMessage(actual interface{}) string {
+	if len(m.matchedElements(actual)) == 0 {
+		return fmt.Sprintf("Expected some element of %s to match, but it is empty.", formatValue(actual))
+	}
+
+	return listMessages(fmt.Sprintf("Expected some element of %s to match, but none did:", formatValue(actual)), m.failures)
+}
+
+func (m *anyElementMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected no element of %s to match, but %s did:\n  %s", formatValue(actual), m.matched.label,
+		indent(m.matcher.NegatedFailureMessage(m.matched.value)))
+}
+
+func (m *anyElementMatcher) String() string {
+	return describeCall("AnyElement", m.matcher)
+}
+
+func (e AssertValue) HasLen(length int) {
+	e.to(HaveLen(length))
+}
+
+func (e AssertValue) IsEmpty() {
+	e.to(BeEmpty())
+}
+
+func (e AssertValue) IsNotEmpty() {
+	e.notTo(BeEmpty())
+}
+
+func (e AssertValue) Contains(element interface{}) {
+	e.to(ContainElement(element))
+}
+
+func (e AssertValue) DoesNotContain(element interface{}) {
+	e.notTo(ContainElement(element))
+}
+
+func (e AssertValue) ContainsAll(elements ...interface{}) {
+	e.to(ContainElements(elements...))
+}
+
+func (e AssertValue) ContainsExactlyInAnyOrder(elements ...interface{}) {
+	e.to(ConsistOf(elements...))
+}
+
+func (e AssertValue) ContainsInOrder(elements ...interface{}) {
+	e.to(ContainElementsInOrder(elements...))
+}
+
+func (e AssertValue) HasKey(key interface{}) {
+	e.to(HaveKey(key))
+}
+
+func (e AssertValue) HasKeyWithValue(key, value interface{}) {
+	e.to(HaveKeyWithValue(key, value))
+}
+
+func (e AssertValue) AllSatisfy(matcher Matcher) {
+	e.to(AllElements(matcher))
+}
+
+func (e AssertValue) AnySatisfy(matcher Matcher) {
+	e.to(AnyElement(matcher))
+}
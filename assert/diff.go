@@ -0,0 +1,570 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Maximum number of characters of a value shown in a failure message
+const maxValueLength = 300
+
+// Maximum number of differences listed in a failure message
+const maxDifferences = 20
+
+// Maximum number of lines compared when diffing multi-line strings, beyond which only the first difference is shown
+const maxDiffLines = 1000
+
+// A reference to a value, along with its type as a struct and its first field share an address
+type reference struct {
+	pointer uintptr
+	typ     reflect.Type
+}
+
+// Formats values in Go syntax for failure messages. Formatting stops once maxValueLength characters have been
+// written, so that large values are not formatted in full only to be cut short.
+type valueFormatter struct {
+	buf strings.Builder
+	//Number of characters written, and of characters left out once the limit was reached
+	length  int
+	dropped int
+	//Set when formatting stopped before the end of the value, so the number of characters left out is unknown
+	stopped bool
+	//References being formatted, to find cycles, and references already formatted, which are only shown once
+	visiting map[reference]bool
+	shown    map[reference]bool
+}
+
+// Formats a value for a failure message in Go syntax. Pointers are followed, cycles are shown as <cycle> and a
+// value shared by several pointers is only shown the first time.
+func formatValue(value interface{}) string {
+	return formatReflectValue(reflect.ValueOf(value))
+}
+
+func formatReflectValue(v reflect.Value) string {
+	f := valueFormatter{visiting: map[reference]bool{}, shown: map[reference]bool{}}
+	f.format(v)
+
+	return f.String()
+}
+
+func (f *valueFormatter) String() string {
+	switch {
+	case f.stopped:
+		return f.buf.String() + "..."
+	case f.dropped > 0:
+		return fmt.Sprintf("%s... (%d more characters)", f.buf.String(), f.dropped)
+	}
+
+	return f.buf.String()
+}
+
+func (f *valueFormatter) write(s string) {
+	for _, r := range s {
+		if f.length < maxValueLength {
+			f.buf.WriteRune(r)
+			f.length++
+		} else {
+			f.dropped++
+		}
+	}
+}
+
+// Reports whether the limit has been reached, in which case the rest of the value is left out
+func (f *valueFormatter) full() bool {
+	if f.length >= maxValueLength {
+		f.stopped = true
+	}
+
+	return f.stopped
+}
+
+func (f *valueFormatter) format(v reflect.Value) {
+	//Matchers given in place of expected values are described the way they were created
+	if v.IsValid() && v.CanInterface() && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		if _, isMatcher := v.Interface().(Matcher); isMatcher {
+			if description, ok := v.Interface().(fmt.Stringer); ok {
+				f.write(description.String())
+				return
+			}
+		}
+	}
+
+	//Types which know how to show themselves, such as time.Time, are not broken down into their fields
+	if v.IsValid() && v.CanInterface() && v.Kind() != reflect.Ptr {
+		switch x := v.Interface().(type) {
+		case time.Duration:
+			f.write(x.String())
+			return
+		case fmt.GoStringer:
+			f.write(x.GoString())
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		f.write("nil")
+	case reflect.Interface:
+		if v.IsNil() {
+			f.write("nil")
+			return
+		}
+
+		f.format(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			f.write(fmt.Sprintf("(%s)(nil)", v.Type()))
+			return
+		}
+
+		if f.enter(reference{v.Pointer(), v.Type()}) {
+			defer delete(f.visiting, reference{v.Pointer(), v.Type()})
+			f.write("&")
+			f.format(v.Elem())
+		}
+	case reflect.Struct:
+		f.write(v.Type().String() + "{")
+
+		for i := 0; i < v.NumField(); i++ {
+			if i > 0 {
+				f.write(", ")
+			}
+
+			if f.full() {
+				return
+			}
+
+			f.write(v.Type().Field(i).Name + ":")
+			f.format(v.Field(i))
+		}
+
+		f.write("}")
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				f.write(fmt.Sprintf("%s(nil)", v.Type()))
+				return
+			}
+
+			//Slices sharing an array may hold different elements of it, so they are only checked for cycles
+			ref := reference{v.Pointer(), v.Type()}
+
+			if f.visiting[ref] && v.Len() > 0 {
+				f.write("<cycle>")
+				return
+			}
+
+			f.visiting[ref] = true
+			defer delete(f.visiting, ref)
+		}
+
+		//Byte slices are usually text
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			f.write(fmt.Sprintf("%s(%q)", v.Type(), v.Bytes()))
+			return
+		}
+
+		f.write(v.Type().String() + "{")
+
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				f.write(", ")
+			}
+
+			if f.full() {
+				return
+			}
+
+			f.format(v.Index(i))
+		}
+
+		f.write("}")
+	case reflect.Map:
+		if v.IsNil() {
+			f.write(fmt.Sprintf("%s(nil)", v.Type()))
+			return
+		}
+
+		if !f.enter(reference{v.Pointer(), v.Type()}) {
+			return
+		}
+
+		defer delete(f.visiting, reference{v.Pointer(), v.Type()})
+
+		f.write(v.Type().String() + "{")
+
+		for i, key := range sortedKeys(v) {
+			if i > 0 {
+				f.write(", ")
+			}
+
+			if f.full() {
+				return
+			}
+
+			f.format(key)
+			f.write(":")
+			f.format(v.MapIndex(key))
+		}
+
+		f.write("}")
+	default:
+		//fmt formats the value held by a reflect.Value, including values of unexported fields
+		f.write(fmt.Sprintf("%#v", v))
+	}
+}
+
+// Records that a reference is being formatted and reports whether its value should be shown. A reference which is
+// already being formatted is shown as <cycle>, and one which was formatted before as <already shown>.
+func (f *valueFormatter) enter(ref reference) bool {
+	switch {
+	case f.visiting[ref]:
+		f.write("<cycle>")
+		return false
+	case f.shown[ref]:
+		f.write("<already shown>")
+		return false
+	}
+
+	f.visiting[ref] = true
+	f.shown[ref] = true
+
+	return true
+}
+
+// Returns the keys of a map ordered by their formatted values so that differences are listed in a stable order
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	formatted := make(map[int]string, len(keys))
+
+	for i, key := range keys {
+		formatted[i] = fmt.Sprintf("%#v", key)
+	}
+
+	indexes := make([]int, len(keys))
+
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return formatted[indexes[i]] < formatted[indexes[j]]
+	})
+
+	sorted := make([]reflect.Value, len(keys))
+
+	for i, index := range indexes {
+		sorted[i] = keys[index]
+	}
+
+	return sorted
+}
+
+// Pair of references being compared, used to stop at cycles
+type visit struct {
+	expected uintptr
+	actual   uintptr
+	typ      reflect.Type
+}
+
+// Collects the differences between an expected and an actual value
+type differ struct {
+	differences []string
+	count       int
+	visited     map[visit]bool
+}
+
+func (d *differ) add(path string, format string, args ...interface{}) {
+	d.count++
+
+	if len(d.differences) < maxDifferences {
+		if path == "" {
+			path = "value"
+		}
+
+		d.differences = append(d.differences, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (d *differ) changed(path string, expected, actual reflect.Value) {
+	d.add(path, "expected %s, actual %s", formatReflectValue(expected),
+		formatReflectValue(actual))
+}
+
+// Reports whether the values of two references have already been compared, or are being compared higher up, and
+// records that they are being compared
+func (d *differ) seen(expected, actual reflect.Value) bool {
+	key := visit{expected.Pointer(), actual.Pointer(), expected.Type()}
+
+	if d.visited[key] {
+		return true
+	}
+
+	d.visited[key] = true
+
+	return false
+}
+
+// Compares the leaf values of two values of the same kind, which may be unexported fields
+func equalLeaves(expected, actual reflect.Value) bool {
+	switch expected.Kind() {
+	case reflect.Bool:
+		return expected.Bool() == actual.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return expected.Int() == actual.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return expected.Uint() == actual.Uint()
+	case reflect.Float32, reflect.Float64:
+		return expected.Float() == actual.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return expected.Complex() == actual.Complex()
+	case reflect.String:
+		return expected.String() == actual.String()
+	case reflect.Func:
+		return expected.IsNil() && actual.IsNil()
+	case reflect.Chan, reflect.UnsafePointer:
+		return expected.Pointer() == actual.Pointer()
+	}
+
+	return false
+}
+
+func (d *differ) diff(path string, expected, actual reflect.Value) {
+	if !expected.IsValid() || !actual.IsValid() {
+		if expected.IsValid() != actual.IsValid() {
+			d.changed(path, expected, actual)
+		}
+
+		return
+	}
+
+	if expected.Type() != actual.Type() {
+		d.add(path, "expected %s of type %s, actual %s of type %s",
+			formatReflectValue(expected), expected.Type(),
+			formatReflectValue(actual), actual.Type())
+		return
+	}
+
+	//Structs which know how to show themselves, such as time.Time, are compared as a whole
+	if expected.Kind() == reflect.Struct && expected.CanInterface() && actual.CanInterface() {
+		if _, ok := expected.Interface().(fmt.GoStringer); ok {
+			if !reflect.DeepEqual(expected.Interface(), actual.Interface()) {
+				d.changed(path, expected, actual)
+			}
+
+			return
+		}
+	}
+
+	switch expected.Kind() {
+	case reflect.Interface:
+		d.diff(path, expected.Elem(), actual.Elem())
+	case reflect.Ptr:
+		if expected.IsNil() || actual.IsNil() {
+			if expected.IsNil() != actual.IsNil() {
+				d.changed(path, expected, actual)
+			}
+
+			return
+		}
+
+		if expected.Pointer() == actual.Pointer() || d.seen(expected, actual) {
+			return
+		}
+
+		d.diff(path, expected.Elem(), actual.Elem())
+	case reflect.Struct:
+		for i := 0; i < expected.NumField(); i++ {
+			d.diff(path+"."+expected.Type().Field(i).Name, expected.Field(i), actual.Field(i))
+		}
+	case reflect.Map:
+		if expected.IsNil() != actual.IsNil() {
+			d.changed(path, expected, actual)
+			return
+		}
+
+		if expected.Pointer() == actual.Pointer() || d.seen(expected, actual) {
+			return
+		}
+
+		for _, key := range sortedKeys(expected) {
+			keyPath := fmt.Sprintf("%s[%s]", path, formatReflectValue(key))
+
+			if value := actual.MapIndex(key); value.IsValid() {
+				d.diff(keyPath, expected.MapIndex(key), value)
+			} else {
+				d.add(keyPath, "missing from actual, expected %s", formatReflectValue(expected.MapIndex(key)))
+			}
+		}
+
+		for _, key := range sortedKeys(actual) {
+			if !expected.MapIndex(key).IsValid() {
+				keyPath := fmt.Sprintf("%s[%s]", path, formatReflectValue(key))
+				d.add(keyPath, "unexpected in actual, got %s", formatReflectValue(actual.MapIndex(key)))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if expected.Kind() == reflect.Slice {
+			if expected.IsNil() != actual.IsNil() {
+				d.changed(path, expected, actual)
+				return
+			}
+
+			if (expected.Pointer() == actual.Pointer() && expected.Len() == actual.Len()) || d.seen(expected, actual) {
+				return
+			}
+		}
+
+		if expected.Len() != actual.Len() {
+			d.add(path, "expected length %d, actual length %d", expected.Len(), actual.Len())
+		}
+
+		for i := 0; i < expected.Len() || i < actual.Len(); i++ {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+
+			switch {
+			case i >= actual.Len():
+				d.add(elementPath, "missing from actual, expected %s", formatReflectValue(expected.Index(i)))
+			case i >= expected.Len():
+				d.add(elementPath, "unexpected in actual, got %s", formatReflectValue(actual.Index(i)))
+			default:
+				d.diff(elementPath, expected.Index(i), actual.Index(i))
+			}
+		}
+	case reflect.String:
+		if expected.String() == actual.String() {
+			return
+		}
+
+		if strings.Contains(expected.String(), "\n") || strings.Contains(actual.String(), "\n") {
+			d.add(path, "strings differ\n%s", diffLines(expected.String(), actual.String()))
+			return
+		}
+
+		//Long strings are truncated when formatted, which can hide the difference
+		if isLongString(expected.String()) || isLongString(actual.String()) {
+			d.add(path, "%s", stringDifference(expected.String(), actual.String(), noSkip, sameRune))
+			return
+		}
+
+		d.changed(path, expected, actual)
+	default:
+		if !equalLeaves(expected, actual) {
+			d.changed(path, expected, actual)
+		}
+	}
+}
+
+// Returns the differences between an expected and an actual value, one per line, naming the path of each
+// differing field, map entry or element
+func diffValues(expected, actual interface{}) []string {
+	d := differ{visited: map[visit]bool{}}
+	d.diff("", reflect.ValueOf(expected), reflect.ValueOf(actual))
+
+	if d.count > len(d.differences) {
+		d.differences = append(d.differences, fmt.Sprintf("... and %d more differences", d.count-len(d.differences)))
+	}
+
+	return d.differences
+}
+
+// Returns a line by line diff of two multi-line strings, with lines only in the expected string marked with -
+// and lines only in the actual string marked with +. Unchanged lines next to changes are shown for context.
+func diffLines(expected, actual string) string {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+
+	if len(expectedLines) > maxDiffLines || len(actualLines) > maxDiffLines {
+		for i := 0; ; i++ {
+			if i >= len(expectedLines) || i >= len(actualLines) || expectedLines[i] != actualLines[i] {
+				return fmt.Sprintf("first difference at line %d", i+1)
+			}
+		}
+	}
+
+	//Lengths of the longest common subsequences of the remaining lines
+	lcs := make([][]int, len(expectedLines)+1)
+
+	for i := range lcs {
+		lcs[i] = make([]int, len(actualLines)+1)
+	}
+
+	for i := len(expectedLines) - 1; i >= 0; i-- {
+		for j := len(actualLines) - 1; j >= 0; j-- {
+			if expectedLines[i] == actualLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type diffLine struct {
+		marker string
+		text   string
+	}
+
+	var lines []diffLine
+
+	i, j := 0, 0
+
+	for i < len(expectedLines) || j < len(actualLines) {
+		switch {
+		case i < len(expectedLines) && j < len(actualLines) && expectedLines[i] == actualLines[j]:
+			lines = append(lines, diffLine{" ", expectedLines[i]})
+			i++
+			j++
+		case j >= len(actualLines) || (i < len(expectedLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{"-", expectedLines[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{"+", actualLines[j]})
+			j++
+		}
+	}
+
+	const context = 2
+
+	var out []string
+
+	for index, line := range lines {
+		near := false
+
+		for k := index - context; k <= index+context; k++ {
+			if k >= 0 && k < len(lines) && lines[k].marker != " " {
+				near = true
+			}
+		}
+
+		if near {
+			out = append(out, line.marker+" "+line.text)
+		} else if len(out) > 0 && out[len(out)-1] != "  ..." {
+			out = append(out, "  ...")
+		}
+	}
+
+	return strings.TrimSuffix(strings.Join(out, "\n"), "\n  ...")
+}
+
+// Returns the failure message for an expected and an actual value which are not equal
+func notEqualMessage(expected, actual interface{}) string {
+	message := fmt.Sprintf("Expected values to be equal.\nexpected: %s\nactual:   %s", formatValue(expected), formatValue(actual))
+	differences := diffValues(expected, actual)
+
+	//A single difference of the whole value says no more than the values themselves
+	if len(differences) == 0 || (len(differences) == 1 && strings.HasPrefix(differences[0], "value: expected ")) {
+		return message
+	}
+
+	for i, difference := range differences {
+		//Indent line diffs of strings under their path
+		differences[i] = "  " + strings.Replace(difference, "\n", "\n    ", -1)
+	}
+
+	return message + "\ndifferences:\n" + strings.Join(differences, "\n")
+}
@@ -0,0 +1,330 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Matcher checks a value in an assertion made with Expect. Matchers can be combined with And, Or, Not and
+// WithTransform, and any type implementing Matcher can be used alongside the built in ones. The failure messages are
+// asked for after Match, which can record what they report, so that the value is only checked once.
+type Matcher interface {
+	//Reports whether the actual value matches
+	Match(actual interface{}) bool
+	//Returns the message for an expectation that the actual value matches which failed
+	FailureMessage(actual interface{}) string
+	//Returns the message for an expectation that the actual value does not match which failed
+	NegatedFailureMessage(actual interface{}) string
+}
+
+// Describes a matcher the way it was created, e.g. BeGreaterThan(0), for the failure messages of matchers which
+// take it in place of an expected value
+func describeCall(name string, args ...interface{}) string {
+	formatted := make([]string, len(args))
+
+	for i, arg := range args {
+		formatted[i] = formatValue(arg)
+	}
+
+	return name + "(" + strings.Join(formatted, ", ") + ")"
+}
+
+func matcherArgs(matchers []Matcher) []interface{} {
+	args := make([]interface{}, len(matchers))
+
+	for i, matcher := range matchers {
+		args[i] = matcher
+	}
+
+	return args
+}
+
+// Expectation is a value to be checked with matchers
+type Expectation struct {
+	value interface{}
+}
+
+// Expect starts an assertion about a value which is checked with a Matcher: Expect(x).To(Equal(5))
+func Expect(val interface{}) Expectation {
+	return Expectation{val}
+}
+
+// To fails unless the value matches
+func (e Expectation) To(matcher Matcher) {
+	if !matcher.Match(e.value) {
+		fail(matcher.FailureMessage(e.value))
+	}
+}
+
+// NotTo fails if the value matches
+func (e Expectation) NotTo(matcher Matcher) {
+	if matcher.Match(e.value) {
+		fail(matcher.NegatedFailureMessage(e.value))
+	}
+}
+
+// Indents the continuation lines of a message listed within another message
+func indent(message string) string {
+	return strings.Replace(message, "\n", "\n  ", -1)
+}
+
+// Joins the messages of several matchers under a heading
+func listMessages(heading string, messages []string) string {
+	for i, message := range messages {
+		messages[i] = "  " + indent(message)
+	}
+
+	return heading + "\n" + strings.Join(messages, "\n")
+}
+
+type nilMatcher struct{}
+
+// BeNil matches nil and the zero values of types which can be nil, as IsNil does
+func BeNil() Matcher {
+	return nilMatcher{}
+}
+
+func (nilMatcher) Match(actual interface{}) bool {
+	return areEqualValues(actual, nil)
+}
+
+func (nilMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be nil.", formatValue(actual))
+}
+
+func (nilMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be nil.", formatValue(actual))
+}
+
+func (nilMatcher) String() string {
+	return "BeNil()"
+}
+
+type equalMatcher struct {
+	expected interface{}
+}
+
+// Equal matches values equal to the expected value, as IsEqualTo does
+func Equal(expected interface{}) Matcher {
+	return equalMatcher{expected}
+}
+
+func (m equalMatcher) Match(actual interface{}) bool {
+	return areEqualValues(actual, m.expected)
+}
+
+func (m equalMatcher) FailureMessage(actual interface{}) string {
+	return notEqualMessage(m.expected, actual)
+}
+
+func (m equalMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be equal to %s.", formatValue(actual), formatValue(m.expected))
+}
+
+func (m equalMatcher) String() string {
+	return describeCall("Equal", m.expected)
+}
+
+type panicMatcher struct{}
+
+// Panic matches functions without arguments which panic when called, as Panics does
+func Panic() Matcher {
+	return panicMatcher{}
+}
+
+func (panicMatcher) Match(actual interface{}) bool {
+	v := reflect.ValueOf(actual)
+
+	if v.Kind() != reflect.Func {
+		fail("Cannot check whether non function object panics.")
+	}
+
+	if v.Type().NumIn() != 0 {
+		fail("Cannot check whether function accepting arguments panics.")
+	}
+
+	return panics(func() { v.Call(nil) })
+}
+
+func (panicMatcher) FailureMessage(actual interface{}) string {
+	return "Expected function to panic but it did not."
+}
+
+func (panicMatcher) NegatedFailureMessage(actual interface{}) string {
+	return "Expected function not to panic but it did."
+}
+
+func (panicMatcher) String() string {
+	return "Panic()"
+}
+
+type andMatcher struct {
+	matchers []Matcher
+	//First matcher which did not match, recorded by Match
+	failed Matcher
+}
+
+// And matches values which all of the matchers match. The failure message is that of the first matcher which
+// does not match.
+func And(matchers ...Matcher) Matcher {
+	return &andMatcher{matchers: matchers}
+}
+
+// SatisfyAll is the same as And
+func SatisfyAll(matchers ...Matcher) Matcher {
+	return And(matchers...)
+}
+
+func (m *andMatcher) Match(actual interface{}) bool {
+	m.failed = nil
+
+	for _, matcher := range m.matchers {
+		if !matcher.Match(actual) {
+			m.failed = matcher
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *andMatcher) FailureMessage(actual interface{}) string {
+	return m.failed.FailureMessage(actual)
+}
+
+func (m *andMatcher) NegatedFailureMessage(actual interface{}) string {
+	messages := make([]string, len(m.matchers))
+
+	for i, matcher := range m.matchers {
+		messages[i] = matcher.NegatedFailureMessage(actual)
+	}
+
+	return listMessages("Expected at least one of the following, but none held:", messages)
+}
+
+func (m *andMatcher) String() string {
+	return describeCall("And", matcherArgs(m.matchers)...)
+}
+
+type orMatcher struct {
+	matchers []Matcher
+	//First matcher which matched, recorded by Match
+	matched Matcher
+}
+
+// Or matches values which any of the matchers match. The failure message lists the failure messages of all of them.
+func Or(matchers ...Matcher) Matcher {
+	return &orMatcher{matchers: matchers}
+}
+
+func (m *orMatcher) Match(actual interface{}) bool {
+	m.matched = nil
+
+	for _, matcher := range m.matchers {
+		if matcher.Match(actual) {
+			m.matched = matcher
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *orMatcher) FailureMessage(actual interface{}) string {
+	messages := make([]string, len(m.matchers))
+
+	for i, matcher := range m.matchers {
+		messages[i] = matcher.FailureMessage(actual)
+	}
+
+	return listMessages("Expected at least one of the following, but none held:", messages)
+}
+
+func (m *orMatcher) NegatedFailureMessage(actual interface{}) string {
+	return m.matched.NegatedFailureMessage(actual)
+}
+
+func (m *orMatcher) String() string {
+	return describeCall("Or", matcherArgs(m.matchers)...)
+}
+
+type notMatcher struct {
+	matcher Matcher
+}
+
+// Not matches values which the matcher does not match
+func Not(matcher Matcher) Matcher {
+	return notMatcher{matcher}
+}
+
+func (m notMatcher) Match(actual interface{}) bool {
+	return !m.matcher.Match(actual)
+}
+
+func (m notMatcher) FailureMessage(actual interface{}) string {
+	return m.matcher.NegatedFailureMessage(actual)
+}
+
+func (m notMatcher) NegatedFailureMessage(actual interface{}) string {
+	return m.matcher.FailureMessage(actual)
+}
+
+func (m notMatcher) String() string {
+	return describeCall("Not", m.matcher)
+}
+
+type transformMatcher struct {
+	transform reflect.Value
+	matcher   Matcher
+	//Transformed value, recorded by Match
+	transformed interface{}
+}
+
+// WithTransform matches values which the matcher matches once passed through transform, which must be a function
+// taking one argument and returning one value: Expect(user).To(WithTransform(User.Age, Equal(42)))
+func WithTransform(transform interface{}, matcher Matcher) Matcher {
+	v := reflect.ValueOf(transform)
+
+	if v.Kind() != reflect.Func || v.Type().NumIn() != 1 || v.Type().NumOut() != 1 {
+		fail(fmt.Sprintf("Cannot transform values with %s, a function taking one argument and returning one value is required.", formatValue(transform)))
+	}
+
+	return &transformMatcher{transform: v, matcher: matcher}
+}
+
+func (m *transformMatcher) apply(actual interface{}) interface{} {
+	in := m.transform.Type().In(0)
+	v := reflect.ValueOf(actual)
+
+	if !v.IsValid() {
+		switch in.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+			v = reflect.Zero(in)
+		}
+	}
+
+	if !v.IsValid() || !v.Type().AssignableTo(in) {
+		fail(fmt.Sprintf("Cannot transform %s with a function taking %s.", formatValue(actual), in))
+	}
+
+	return m.transform.Call([]reflect.Value{v})[0].Interface()
+}
+
+func (m *transformMatcher) Match(actual interface{}) bool {
+	m.transformed = m.apply(actual)
+	return m.matcher.Match(m.transformed)
+}
+
+func (m *transformMatcher) FailureMessage(actual interface{}) string {
+	return m.matcher.FailureMessage(m.transformed)
+}
+
+func (m *transformMatcher) NegatedFailureMessage(actual interface{}) string {
+	return m.matcher.NegatedFailureMessage(m.transformed)
+}
+
+func (m *transformMatcher) String() string {
+	return "WithTransform(" + runtime.FuncForPC(m.transform.Pointer()).Name() + ", " + formatValue(m.matcher) + ")"
+}
@@ -0,0 +1,457 @@
+package assert
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// A number of any kind. Kind is reflect.Int, reflect.Uint or reflect.Float64 and says which of i, u or f holds the
+// exact value. f always holds the value, converted if necessary.
+type number struct {
+	kind reflect.Kind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+// Returns the value of an integer, unsigned integer or float of any size, including named types such as
+// time.Duration
+func numberOf(value interface{}) (number, bool) {
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: reflect.Int, i: v.Int(), f: float64(v.Int())}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return number{kind: reflect.Uint, u: v.Uint(), f: float64(v.Uint())}, true
+	case reflect.Float32, reflect.Float64:
+		return number{kind: reflect.Float64, f: v.Float()}, true
+	}
+
+	return number{}, false
+}
+
+func cannotCompare(actual, expected interface{}) {
+	fail(fmt.Sprintf("Cannot compare %s with %s, numbers, time.Durations or time.Times are required.",
+		formatValue(actual), formatValue(expected)))
+}
+
+func sign(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+
+	return 0
+}
+
+// Compares two numbers of any kinds, or two time.Times, returning -1, 0 or 1 as the actual value is less than,
+// equal to or greater than the expected value. Integers are compared exactly. ok is false when either is NaN.
+func compare(actual, expected interface{}) (result int, ok bool) {
+	if actualTime, isTime := actual.(time.Time); isTime {
+		expectedTime, isTime := expected.(time.Time)
+
+		if !isTime {
+			cannotCompare(actual, expected)
+		}
+
+		return sign(actualTime.Before(expectedTime), actualTime.After(expectedTime)), true
+	}
+
+	a, isNumber := numberOf(actual)
+	e, isOtherNumber := numberOf(expected)
+
+	if !isNumber || !isOtherNumber {
+		cannotCompare(actual, expected)
+	}
+
+	return compareNumbers(a, e)
+}
+
+func compareNumbers(a, e number) (result int, ok bool) {
+	switch {
+	case a.kind == reflect.Int && e.kind == reflect.Int:
+		return sign(a.i < e.i, a.i > e.i), true
+	case a.kind == reflect.Uint && e.kind == reflect.Uint:
+		return sign(a.u < e.u, a.u > e.u), true
+	case a.kind == reflect.Int && e.kind == reflect.Uint:
+		return sign(a.i < 0 || uint64(a.i) < e.u, a.i >= 0 && uint64(a.i) > e.u), true
+	case a.kind == reflect.Uint && e.kind == reflect.Int:
+		return sign(e.i >= 0 && a.u < uint64(e.i), e.i < 0 || a.u > uint64(e.i)), true
+	}
+
+	if math.IsNaN(a.f) || math.IsNaN(e.f) {
+		return 0, false
+	}
+
+	return sign(a.f < e.f, a.f > e.f), true
+}
+
+// Returns the absolute value of an integer, which unlike the integer itself always fits in a uint64
+func magnitude(i int64) uint64 {
+	if i < 0 {
+		return uint64(-(i + 1)) + 1
+	}
+
+	return uint64(i)
+}
+
+// Returns how far apart two integers are. ok is false when either is a float or the distance does not fit in a
+// uint64.
+func integerDistance(a, e number) (d uint64, ok bool) {
+	//Integers wrap around, so subtracting the smaller from the larger gives the exact distance
+	switch {
+	case a.kind == reflect.Int && e.kind == reflect.Int:
+		if a.i < e.i {
+			a, e = e, a
+		}
+
+		return uint64(a.i) - uint64(e.i), true
+	case a.kind == reflect.Uint && e.kind == reflect.Uint:
+		if a.u < e.u {
+			a, e = e, a
+		}
+
+		return a.u - e.u, true
+	case a.kind == reflect.Uint && e.kind == reflect.Int:
+		a, e = e, a
+	case a.kind != reflect.Int || e.kind != reflect.Uint:
+		return 0, false
+	}
+
+	if a.i >= 0 {
+		return integerDistance(number{kind: reflect.Uint, u: uint64(a.i)}, e)
+	}
+
+	if e.u > math.MaxUint64-magnitude(a.i) {
+		return 0, false
+	}
+
+	return e.u + magnitude(a.i), true
+}
+
+// Returns how far apart two numbers, or two time.Times in nanoseconds, are. The distance between two integers is
+// exact.
+func distance(actual, expected interface{}) number {
+	if actualTime, isTime := actual.(time.Time); isTime {
+		expectedTime, isTime := expected.(time.Time)
+
+		if !isTime {
+			cannotCompare(actual, expected)
+		}
+
+		d := magnitude(int64(actualTime.Sub(expectedTime)))
+
+		return number{kind: reflect.Uint, u: d, f: float64(d)}
+	}
+
+	a, isNumber := numberOf(actual)
+	e, isOtherNumber := numberOf(expected)
+
+	if !isNumber || !isOtherNumber {
+		cannotCompare(actual, expected)
+	}
+
+	if d, ok := integerDistance(a, e); ok {
+		return number{kind: reflect.Uint, u: d, f: float64(d)}
+	}
+
+	return number{kind: reflect.Float64, f: math.Abs(a.f - e.f)}
+}
+
+// Formats a distance between two values as a duration for time.Durations and time.Times
+func formatDistance(actual interface{}, d number) string {
+	switch actual.(type) {
+	case time.Time, time.Duration:
+		if d.u <= math.MaxInt64 {
+			return time.Duration(d.u).String()
+		}
+	}
+
+	if d.kind == reflect.Uint {
+		return strconv.FormatUint(d.u, 10)
+	}
+
+	return fmt.Sprintf("%g", d.f)
+}
+
+// Names of the functions creating a comparisonMatcher for each relation. BePositive and BeNegative are described as
+// the comparisons with zero they are.
+var comparisonNames = map[string]string{">": "BeGreaterThan", ">=": "BeGreaterThanOrEqualTo", "<": "BeLessThan",
+	"<=": "BeLessThanOrEqualTo"}
+
+type comparisonMatcher struct {
+	//One of <, <=, > or >=
+	relation string
+	expected interface{}
+	//How the expected relation is described in failure messages
+	description string
+}
+
+func (m comparisonMatcher) Match(actual interface{}) bool {
+	result, ok := compare(actual, m.expected)
+
+	if !ok {
+		return false
+	}
+
+	switch m.relation {
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	}
+
+	return result >= 0
+}
+
+func (m comparisonMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be %s.", formatValue(actual), m.description)
+}
+
+func (m comparisonMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be %s.", formatValue(actual), m.description)
+}
+
+func (m comparisonMatcher) String() string {
+	return describeCall(comparisonNames[m.relation], m.expected)
+}
+
+// BeGreaterThan matches numbers, time.Durations and time.Times greater than the expected value
+func BeGreaterThan(expected interface{}) Matcher {
+	return comparisonMatcher{">", expected, "greater than " + formatValue(expected)}
+}
+
+// BeGreaterThanOrEqualTo matches numbers, time.Durations and time.Times greater than or equal to the expected value
+func BeGreaterThanOrEqualTo(expected interface{}) Matcher {
+	return comparisonMatcher{">=", expected, "greater than or equal to " + formatValue(expected)}
+}
+
+// BeLessThan matches numbers, time.Durations and time.Times less than the expected value
+func BeLessThan(expected interface{}) Matcher {
+	return comparisonMatcher{"<", expected, "less than " + formatValue(expected)}
+}
+
+// BeLessThanOrEqualTo matches numbers, time.Durations and time.Times less than or equal to the expected value
+func BeLessThanOrEqualTo(expected interface{}) Matcher {
+	return comparisonMatcher{"<=", expected, "less than or equal to " + formatValue(expected)}
+}
+
+// BePositive matches numbers and time.Durations greater than zero
+func BePositive() Matcher {
+	return comparisonMatcher{">", 0, "positive"}
+}
+
+// BeNegative matches numbers and time.Durations less than zero
+func BeNegative() Matcher {
+	return comparisonMatcher{"<", 0, "negative"}
+}
+
+type betweenMatcher struct {
+	low  interface{}
+	high interface{}
+}
+
+// BeBetween matches numbers, time.Durations and time.Times from low to high, including low and high
+func BeBetween(low, high interface{}) Matcher {
+	return betweenMatcher{low, high}
+}
+
+func (m betweenMatcher) Match(actual interface{}) bool {
+	aboveLow, lowOk := compare(actual, m.low)
+	belowHigh, highOk := compare(actual, m.high)
+
+	return lowOk && highOk && aboveLow >= 0 && belowHigh <= 0
+}
+
+func (m betweenMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be between %s and %s.", formatValue(actual), formatValue(m.low), formatValue(m.high))
+}
+
+func (m betweenMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be between %s and %s.", formatValue(actual), formatValue(m.low), formatValue(m.high))
+}
+
+func (m betweenMatcher) String() string {
+	return describeCall("BeBetween", m.low, m.high)
+}
+
+type closeToMatcher struct {
+	expected interface{}
+	epsilon  interface{}
+}
+
+// BeCloseTo matches numbers, time.Durations and time.Times no further than epsilon from the expected value. Epsilon
+// is a time.Duration for time.Times.
+func BeCloseTo(expected, epsilon interface{}) Matcher {
+	return closeToMatcher{expected, epsilon}
+}
+
+func (m closeToMatcher) epsilonValue() number {
+	epsilon, isNumber := numberOf(m.epsilon)
+
+	if !isNumber {
+		fail(fmt.Sprintf("Cannot use %s as the greatest difference, a number or time.Duration is required.", formatValue(m.epsilon)))
+	}
+
+	return epsilon
+}
+
+func (m closeToMatcher) Match(actual interface{}) bool {
+	result, ok := compareNumbers(distance(actual, m.expected), m.epsilonValue())
+	return ok && result <= 0
+}
+
+func (m closeToMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be within %s of %s, but it differs by %s.", formatValue(actual), formatValue(m.epsilon),
+		formatValue(m.expected), formatDistance(actual, distance(actual, m.expected)))
+}
+
+func (m closeToMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be within %s of %s, but it differs by %s.", formatValue(actual), formatValue(m.epsilon),
+		formatValue(m.expected), formatDistance(actual, distance(actual, m.expected)))
+}
+
+func (m closeToMatcher) String() string {
+	return describeCall("BeCloseTo", m.expected, m.epsilon)
+}
+
+type withinPercentMatcher struct {
+	expected interface{}
+	percent  float64
+}
+
+// BeWithinPercent matches numbers and time.Durations which differ from the expected value by no more than the given
+// percentage of it
+func BeWithinPercent(expected interface{}, percent float64) Matcher {
+	return withinPercentMatcher{expected, percent}
+}
+
+// Returns by what percentage of the expected value the actual value differs from it
+func (m withinPercentMatcher) difference(actual interface{}) float64 {
+	e, isNumber := numberOf(m.expected)
+
+	if !isNumber {
+		cannotCompare(actual, m.expected)
+	}
+
+	return distance(actual, m.expected).f / math.Abs(e.f) * 100
+}
+
+func (m withinPercentMatcher) Match(actual interface{}) bool {
+	//The difference is NaN when both values are zero
+	return m.difference(actual) <= m.percent || distance(actual, m.expected).f == 0
+}
+
+func (m withinPercentMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be within %g%% of %s, but it differs by %.3g%%.", formatValue(actual), m.percent,
+		formatValue(m.expected), m.difference(actual))
+}
+
+func (m withinPercentMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be within %g%% of %s, but it differs by %.3g%%.", formatValue(actual), m.percent,
+		formatValue(m.expected), m.difference(actual))
+}
+
+func (m withinPercentMatcher) String() string {
+	return describeCall("BeWithinPercent", m.expected, m.percent)
+}
+
+type floatMatcher struct {
+	//Name of the function which created the matcher
+	name        string
+	description string
+	test        func(float64) bool
+}
+
+func (m floatMatcher) Match(actual interface{}) bool {
+	n, isNumber := numberOf(actual)
+
+	if !isNumber {
+		fail(fmt.Sprintf("Cannot check whether %s is %s, a number is required.", formatValue(actual), m.description))
+	}
+
+	return m.test(n.f)
+}
+
+func (m floatMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be %s.", formatValue(actual), m.description)
+}
+
+func (m floatMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not be %s.", formatValue(actual), m.description)
+}
+
+func (m floatMatcher) String() string {
+	return m.name + "()"
+}
+
+// BeNaN matches floats which are not a number
+func BeNaN() Matcher {
+	return floatMatcher{"BeNaN", "NaN", math.IsNaN}
+}
+
+// BeInf matches positive and negative infinity
+func BeInf() Matcher {
+	return floatMatcher{"BeInf", "infinite", func(f float64) bool { return math.IsInf(f, 0) }}
+}
+
+// BeFinite matches numbers which are neither infinite nor NaN
+func BeFinite() Matcher {
+	return floatMatcher{"BeFinite", "finite", func(f float64) bool { return !math.IsInf(f, 0) && !math.IsNaN(f) }}
+}
+
+func (e AssertValue) IsGreaterThan(expected interface{}) {
+	e.to(BeGreaterThan(expected))
+}
+
+func (e AssertValue) IsGreaterThanOrEqualTo(expected interface{}) {
+	e.to(BeGreaterThanOrEqualTo(expected))
+}
+
+func (e AssertValue) IsLessThan(expected interface{}) {
+	e.to(BeLessThan(expected))
+}
+
+func (e AssertValue) IsLessThanOrEqualTo(expected interface{}) {
+	e.to(BeLessThanOrEqualTo(expected))
+}
+
+func (e AssertValue) IsBetween(low, high interface{}) {
+	e.to(BeBetween(low, high))
+}
+
+func (e AssertValue) IsPositive() {
+	e.to(BePositive())
+}
+
+func (e AssertValue) IsNegative() {
+	e.to(BeNegative())
+}
+
+func (e AssertValue) IsCloseTo(expected, epsilon interface{}) {
+	e.to(BeCloseTo(expected, epsilon))
+}
+
+func (e AssertValue) IsWithinPercent(expected interface{}, percent float64) {
+	e.to(BeWithinPercent(expected, percent))
+}
+
+func (e AssertValue) IsNaN() {
+	e.to(BeNaN())
+}
+
+func (e AssertValue) IsInf() {
+	e.to(BeInf())
+}
+
+func (e AssertValue) IsFinite() {
+	e.to(BeFinite())
+}
@@ -0,0 +1,601 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Strings longer than this many characters, or with more than one line, are shown with the position of their first
+// difference in failure messages
+const longStringLength = 60
+
+// Number of characters shown either side of a difference in a long string
+const excerptContext = 30
+
+// Returns the text of a string, []byte, error or fmt.Stringer
+func stringOf(actual interface{}) string {
+	switch v := actual.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	v := reflect.ValueOf(actual)
+
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return string(v.Bytes())
+	}
+
+	fail(fmt.Sprintf("Cannot use %s as a string, a string, []byte, error or fmt.Stringer is required.", formatValue(actual)))
+	return ""
+}
+
+func isLongString(s string) bool {
+	return utf8.RuneCountInString(s) > longStringLength || strings.Contains(s, "\n")
+}
+
+// Position of a character in a string. Lines and columns start at 1 and columns count characters rather than bytes.
+type stringPosition struct {
+	offset int
+	line   int
+	column int
+}
+
+func (p stringPosition) String() string {
+	return fmt.Sprintf("line %d, column %d", p.line, p.column)
+}
+
+// Returns the character at a position and the position after it
+func (p stringPosition) next(s string) (rune, stringPosition) {
+	r, size := utf8.DecodeRuneInString(s[p.offset:])
+	p.offset += size
+
+	if r == '\n' {
+		p.line++
+		p.column = 1
+	} else {
+		p.column++
+	}
+
+	return r, p
+}
+
+// Returns the position of a byte offset in a string
+func positionAt(s string, offset int) stringPosition {
+	p := stringPosition{0, 1, 1}
+
+	for p.offset < offset {
+		_, p = p.next(s)
+	}
+
+	return p
+}
+
+// Returns the positions of the first characters which differ between two strings, or false if they do not differ.
+// Characters for which skip returns true are passed over and characters are compared with equal. The position just
+// past the end of a string is returned when it is shorter.
+func firstDifference(expected, actual string, skip func(rune) bool, equal func(a, b rune) bool) (stringPosition, stringPosition, bool) {
+	e, a := stringPosition{0, 1, 1}, stringPosition{0, 1, 1}
+
+	skipFrom := func(s string, p stringPosition) stringPosition {
+		for p.offset < len(s) {
+			r, after := p.next(s)
+
+			if !skip(r) {
+				break
+			}
+
+			p = after
+		}
+
+		return p
+	}
+
+	for {
+		e, a = skipFrom(expected, e), skipFrom(actual, a)
+
+		if e.offset == len(expected) && a.offset == len(actual) {
+			return e, a, false
+		}
+
+		if e.offset == len(expected) || a.offset == len(actual) {
+			return e, a, true
+		}
+
+		er, eNext := e.next(expected)
+		ar, aNext := a.next(actual)
+
+		if !equal(er, ar) {
+			return e, a, true
+		}
+
+		e, a = eNext, aNext
+	}
+}
+
+// Returns the line of a string containing a position, quoted and shortened around the position, along with a
+// line marking the position with ^ under it
+func excerpt(s string, p stringPosition) (string, string) {
+	start := strings.LastIndex(s[:p.offset], "\n") + 1
+	end := strings.Index(s[p.offset:], "\n")
+
+	if end < 0 {
+		end = len(s)
+	} else {
+		end += p.offset
+	}
+
+	line := []rune(s[start:end])
+	from, to := 0, len(line)
+
+	if p.column-1 > excerptContext {
+		from = p.column - 1 - excerptContext
+	}
+
+	if to > p.column-1+excerptContext {
+		to = p.column - 1 + excerptContext
+	}
+
+	before, after := "", ""
+
+	if from > 0 {
+		before = "..."
+	}
+
+	if to < len(line) {
+		after = "..."
+	}
+
+	quoted := before + strconv.Quote(string(line[from:to])) + after
+	//The quoted text before the position, less the closing quote
+	offset := utf8.RuneCountInString(before+strconv.Quote(string(line[from:p.column-1]))) - 1
+
+	return quoted, strings.Repeat(" ", offset) + "^"
+}
+
+// Describes where two strings first differ, showing the differing line of each with the position marked
+func stringDifference(expected, actual string, skip func(rune) bool, equal func(a, b rune) bool) string {
+	e, a, _ := firstDifference(expected, actual, skip, equal)
+	return describeDifference("", expected, actual, e, a)
+}
+
+// Shows the lines of two strings containing a difference at the given positions, with the positions marked. The
+// heading defaults to the positions of the difference.
+func describeDifference(heading string, expected, actual string, e, a stringPosition) string {
+	if heading == "" {
+		heading = "first difference at " + e.String()
+
+		if e.line != a.line || e.column != a.column {
+			heading = fmt.Sprintf("first difference at %s of expected and %s of actual", e, a)
+		}
+	}
+
+	expectedLine, expectedMarker := excerpt(expected, e)
+	actualLine, actualMarker := excerpt(actual, a)
+
+	return fmt.Sprintf("%s\n  expected: %s\n            %s\n  actual:   %s\n            %s", heading,
+		expectedLine, expectedMarker, actualLine, actualMarker)
+}
+
+// Shows the line of a string containing a position, with the position marked
+func describePosition(heading string, actual string, p stringPosition) string {
+	line, marker := excerpt(actual, p)
+	return fmt.Sprintf("%s\n  actual:   %s\n            %s", heading, line, marker)
+}
+
+// Moves a byte offset in a string back to the start of the character containing it
+func runeStart(s string, offset int) int {
+	for offset > 0 && offset < len(s) && !utf8.RuneStart(s[offset]) {
+		offset--
+	}
+
+	return offset
+}
+
+func noSkip(rune) bool {
+	return false
+}
+
+func sameRune(a, b rune) bool {
+	return a == b
+}
+
+func sameRuneIgnoringCase(a, b rune) bool {
+	return strings.EqualFold(string(a), string(b))
+}
+
+// Adds where two strings differ to a failure message when they are too long to compare by eye
+func withStringDifference(message string, expected, actual string, skip func(rune) bool, equal func(a, b rune) bool) string {
+	if !isLongString(expected) && !isLongString(actual) {
+		return message
+	}
+
+	return message + "\n" + stringDifference(expected, actual, skip, equal)
+}
+
+type prefixMatcher struct {
+	prefix string
+}
+
+// HavePrefix matches strings starting with the prefix
+func HavePrefix(prefix string) Matcher {
+	return prefixMatcher{prefix}
+}
+
+func (m prefixMatcher) Match(actual interface{}) bool {
+	return strings.HasPrefix(stringOf(actual), m.prefix)
+}
+
+func (m prefixMatcher) FailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	message := fmt.Sprintf("Expected %s to start with %s.", formatValue(s), formatValue(m.prefix))
+
+	if len(s) > len(m.prefix) {
+		s = s[:len(m.prefix)]
+	}
+
+	return withStringDifference(message, m.prefix, s, noSkip, sameRune)
+}
+
+func (m prefixMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not start with %s.", formatValue(stringOf(actual)), formatValue(m.prefix))
+}
+
+func (m prefixMatcher) String() string {
+	return describeCall("HavePrefix", m.prefix)
+}
+
+type suffixMatcher struct {
+	suffix string
+}
+
+// HaveSuffix matches strings ending with the suffix
+func HaveSuffix(suffix string) Matcher {
+	return suffixMatcher{suffix}
+}
+
+func (m suffixMatcher) Match(actual interface{}) bool {
+	return strings.HasSuffix(stringOf(actual), m.suffix)
+}
+
+func (m suffixMatcher) FailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	message := fmt.Sprintf("Expected %s to end with %s.", formatValue(s), formatValue(m.suffix))
+
+	if !isLongString(s) && !isLongString(m.suffix) {
+		return message
+	}
+
+	//The end of the string is lined up with the end of the suffix
+	length := len(m.suffix)
+
+	if len(s) < length {
+		length = len(s)
+	}
+
+	start, suffixStart := runeStart(s, len(s)-length), runeStart(m.suffix, len(m.suffix)-length)
+	e, a, differ := firstDifference(m.suffix[suffixStart:], s[start:], noSkip, sameRune)
+
+	//The string is the end of a longer suffix
+	if !differ {
+		e, a = stringPosition{}, stringPosition{}
+	}
+
+	return message + "\n" + describeDifference("", m.suffix, s, positionAt(m.suffix, suffixStart+e.offset), positionAt(s, start+a.offset))
+}
+
+func (m suffixMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not end with %s.", formatValue(stringOf(actual)), formatValue(m.suffix))
+}
+
+func (m suffixMatcher) String() string {
+	return describeCall("HaveSuffix", m.suffix)
+}
+
+type substringMatcher struct {
+	substring string
+}
+
+// ContainSubstring matches strings containing the substring
+func ContainSubstring(substring string) Matcher {
+	return substringMatcher{substring}
+}
+
+func (m substringMatcher) Match(actual interface{}) bool {
+	return strings.Contains(stringOf(actual), m.substring)
+}
+
+func (m substringMatcher) FailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	message := fmt.Sprintf("Expected %s to contain %s.", formatValue(s), formatValue(m.substring))
+
+	if !isLongString(s) && !isLongString(m.substring) {
+		return message
+	}
+
+	//The longest start of the substring which is found
+	length := runeStart(m.substring, sort.Search(len(m.substring), func(n int) bool {
+		return !strings.Contains(s, m.substring[:n+1])
+	}))
+
+	if length == 0 {
+		return message
+	}
+
+	offset := strings.Index(s, m.substring[:length])
+	heading := fmt.Sprintf("its first %d characters are found at %s", utf8.RuneCountInString(m.substring[:length]),
+		positionAt(s, offset))
+
+	return message + "\n" + describeDifference(heading, m.substring, s, positionAt(m.substring, length),
+		positionAt(s, offset+length))
+}
+
+func (m substringMatcher) NegatedFailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+
+	return fmt.Sprintf("Expected %s to not contain %s, but it does at %s.", formatValue(s), formatValue(m.substring),
+		positionAt(s, strings.Index(s, m.substring)))
+}
+
+func (m substringMatcher) String() string {
+	return describeCall("ContainSubstring", m.substring)
+}
+
+type regexMatcher struct {
+	regex *regexp.Regexp
+}
+
+// MatchRegex matches strings containing a match of the regular expression. Use ^ and $ to match the whole string.
+func MatchRegex(pattern string) Matcher {
+	regex, err := regexp.Compile(pattern)
+
+	if err != nil {
+		fail(fmt.Sprintf("Invalid regular expression %q: %s", pattern, err))
+	}
+
+	return regexMatcher{regex}
+}
+
+func (m regexMatcher) Match(actual interface{}) bool {
+	return m.regex.MatchString(stringOf(actual))
+}
+
+func (m regexMatcher) FailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	message := fmt.Sprintf("Expected %s to match regular expression %q.", formatValue(s), m.regex)
+
+	if !isLongString(s) {
+		return message
+	}
+
+	if end := partialMatchEnd(m.regex, s); end >= 0 {
+		p := positionAt(s, end)
+		return message + "\n" + describePosition("its longest start which matches ends at "+p.String(), s, p)
+	}
+
+	return message
+}
+
+// Returns the end of the leftmost match of the longest start of a regular expression which matches a string, or -1
+// if no part of it does. Literals are split into their characters so that they can match in part.
+func partialMatchEnd(regex *regexp.Regexp, s string) int {
+	parsed, err := syntax.Parse(regex.String(), syntax.Perl)
+
+	if err != nil {
+		return -1
+	}
+
+	parts := []*syntax.Regexp{parsed}
+
+	if parsed.Op == syntax.OpConcat {
+		parts = parsed.Sub
+	}
+
+	var steps []*syntax.Regexp
+
+	for _, part := range parts {
+		if part.Op != syntax.OpLiteral {
+			steps = append(steps, part)
+			continue
+		}
+
+		for _, r := range part.Rune {
+			steps = append(steps, &syntax.Regexp{Op: syntax.OpLiteral, Flags: part.Flags, Rune: []rune{r}})
+		}
+	}
+
+	start := func(n int) *regexp.Regexp {
+		compiled, err := regexp.Compile((&syntax.Regexp{Op: syntax.OpConcat, Sub: steps[:n]}).String())
+
+		if err != nil {
+			return nil
+		}
+
+		return compiled
+	}
+
+	length := sort.Search(len(steps), func(n int) bool {
+		compiled := start(n + 1)
+		return compiled == nil || !compiled.MatchString(s)
+	})
+
+	if length == 0 {
+		return -1
+	}
+
+	return start(length).FindStringIndex(s)[1]
+}
+
+func (m regexMatcher) NegatedFailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	match := m.regex.FindStringIndex(s)
+
+	return fmt.Sprintf("Expected %s to not match regular expression %q, but %s at %s does.", formatValue(s), m.regex,
+		formatValue(s[match[0]:match[1]]), positionAt(s, match[0]))
+}
+
+func (m regexMatcher) String() string {
+	return describeCall("MatchRegex", m.regex.String())
+}
+
+type equalIgnoringCaseMatcher struct {
+	expected string
+}
+
+// EqualIgnoringCase matches strings equal to the expected string when upper and lower case letters are not told apart
+func EqualIgnoringCase(expected string) Matcher {
+	return equalIgnoringCaseMatcher{expected}
+}
+
+func (m equalIgnoringCaseMatcher) Match(actual interface{}) bool {
+	return strings.EqualFold(stringOf(actual), m.expected)
+}
+
+func (m equalIgnoringCaseMatcher) FailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	message := fmt.Sprintf("Expected %s to equal %s ignoring case.", formatValue(s), formatValue(m.expected))
+
+	return withStringDifference(message, m.expected, s, noSkip, sameRuneIgnoringCase)
+}
+
+func (m equalIgnoringCaseMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not equal %s ignoring case.", formatValue(stringOf(actual)), formatValue(m.expected))
+}
+
+func (m equalIgnoringCaseMatcher) String() string {
+	return describeCall("EqualIgnoringCase", m.expected)
+}
+
+type equalIgnoringWhitespaceMatcher struct {
+	expected string
+}
+
+// EqualIgnoringWhitespace matches strings equal to the expected string once all whitespace is removed from both
+func EqualIgnoringWhitespace(expected string) Matcher {
+	return equalIgnoringWhitespaceMatcher{expected}
+}
+
+func (m equalIgnoringWhitespaceMatcher) Match(actual interface{}) bool {
+	_, _, differ := firstDifference(m.expected, stringOf(actual), unicode.IsSpace, sameRune)
+	return !differ
+}
+
+func (m equalIgnoringWhitespaceMatcher) FailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	message := fmt.Sprintf("Expected %s to equal %s ignoring whitespace.", formatValue(s), formatValue(m.expected))
+
+	return withStringDifference(message, m.expected, s, unicode.IsSpace, sameRune)
+}
+
+func (m equalIgnoringWhitespaceMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not equal %s ignoring whitespace.", formatValue(stringOf(actual)), formatValue(m.expected))
+}
+
+func (m equalIgnoringWhitespaceMatcher) String() string {
+	return describeCall("EqualIgnoringWhitespace", m.expected)
+}
+
+// Returns the number of lines in a string. A final line break does not start another line.
+func lineCount(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	count := strings.Count(s, "\n")
+
+	if !strings.HasSuffix(s, "\n") {
+		count++
+	}
+
+	return count
+}
+
+type lineCountMatcher struct {
+	count int
+}
+
+// HaveLineCount matches strings with the given number of lines
+func HaveLineCount(count int) Matcher {
+	return lineCountMatcher{count}
+}
+
+func (m lineCountMatcher) Match(actual interface{}) bool {
+	return lineCount(stringOf(actual)) == m.count
+}
+
+func (m lineCountMatcher) FailureMessage(actual interface{}) string {
+	s := stringOf(actual)
+	count := lineCount(s)
+	message := fmt.Sprintf("Expected %s to have %d lines, but it has %d.", formatValue(s), m.count, count)
+
+	if !isLongString(s) {
+		return message
+	}
+
+	if count > m.count {
+		offset := 0
+
+		for i := 0; i < m.count; i++ {
+			offset += strings.Index(s[offset:], "\n") + 1
+		}
+
+		return message + "\n" + describePosition(fmt.Sprintf("line %d is the first extra line", m.count+1), s, positionAt(s, offset))
+	}
+
+	//A final line break is shown as the end of the last line
+	end := positionAt(s, len(strings.TrimSuffix(s, "\n")))
+
+	return message + "\n" + describePosition("it ends at "+end.String(), s, end)
+}
+
+func (m lineCountMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to not have %d lines.", formatValue(stringOf(actual)), m.count)
+}
+
+func (m lineCountMatcher) String() string {
+	return describeCall("HaveLineCount", m.count)
+}
+
+func (e AssertValue) StartsWith(prefix string) {
+	e.to(HavePrefix(prefix))
+}
+
+func (e AssertValue) EndsWith(suffix string) {
+	e.to(HaveSuffix(suffix))
+}
+
+func (e AssertValue) ContainsSubstring(substring string) {
+	e.to(ContainSubstring(substring))
+}
+
+func (e AssertValue) MatchesRegex(pattern string) {
+	e.to(MatchRegex(pattern))
+}
+
+func (e AssertValue) EqualsIgnoringCase(expected string) {
+	e.to(EqualIgnoringCase(expected))
+}
+
+func (e AssertValue) EqualsIgnoringWhitespace(expected string) {
+	e.to(EqualIgnoringWhitespace(expected))
+}
+
+func (e AssertValue) HasLineCount(count int) {
+	e.to(HaveLineCount(count))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// Version of gotest, set for releases with -ldflags "-X main.version=v1.2.3". Otherwise the version of the module
+// gotest was installed from is used.
+var version = ""
+
+// Commands of the gotest command. The tests are run when no command is given.
+var commands = []struct{ name, description string }{
+	{"run", "build and run the tests (the default)"},
+	{"list", "list the tests and where they are declared without running them"},
+	{"watch", "run the tests and rerun the affected tests whenever a file changes"},
+	{"clean", "remove generated packages left in the source tree by older versions (default ./...)"},
+}
+
+func isCommand(arg string) bool {
+	for _, command := range commands {
+		if command.name == arg {
+			return true
+		}
+	}
+
+	return false
+}
+
+func gotestVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return "devel"
+}
+
+func printUsage(out io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(out, "Usage: gotest [command] [options] [packages]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+
+	for _, command := range commands {
+		fmt.Fprintf(out, "  %-7s%s\n", command.name, command.description)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Packages are given as import paths or directories, as with go test, and default to the package in the")
+	fmt.Fprintln(out, "current directory. A trailing /... includes the packages in sub-directories.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Options:")
+
+	flags.SetOutput(out)
+	flags.PrintDefaults()
+	flags.SetOutput(ioutil.Discard)
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Defaults for the options can be set in a %s file in the module root.\n", configFileName)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Exit codes:")
+	fmt.Fprintf(out, "  %d  all tests passed\n", 0)
+	fmt.Fprintf(out, "  %d  tests failed\n", exitTestsFailed)
+	fmt.Fprintf(out, "  %d  the tests did not build\n", exitBuildFailed)
+	fmt.Fprintf(out, "  %d  invalid command line or config file\n", exitUsage)
+	fmt.Fprintf(out, "  %d  any other error\n", exitOtherError)
+}
+
+// Parses the command line, which is an optional command followed by options and package patterns in any order
+func parseCommandLine(flags *flag.FlagSet, args []string) (command string, patterns []string) {
+	//Errors are reported along with the usage rather than by the flag package
+	flags.SetOutput(ioutil.Discard)
+
+	command = "run"
+	first := true
+
+	for {
+		err := flags.Parse(args)
+
+		if err == flag.ErrHelp {
+			printUsage(os.Stdout, flags)
+			os.Exit(0)
+		}
+
+		if err != nil {
+			var usage strings.Builder
+			printUsage(&usage, flags)
+			usageError("%s\n\n%s", err, usage.String())
+		}
+
+		remaining := flags.Args()
+
+		if len(remaining) == 0 {
+			return command, patterns
+		}
+
+		//Everything following -- is a package pattern
+		if len(args) > len(remaining) && args[len(args)-len(remaining)-1] == "--" {
+			return command, append(patterns, remaining...)
+		}
+
+		if first && isCommand(remaining[0]) {
+			command = remaining[0]
+		} else {
+			patterns = append(patterns, remaining[0])
+		}
+
+		first = false
+		args = remaining[1:]
+	}
+}
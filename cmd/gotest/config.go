@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the file in the module root which sets defaults for the options of the gotest command
+const configFileName = ".gotest.yml"
+
+// Returns the path of the config file which applies in the current directory. It is looked for in the root of the
+// enclosing module, or in the current directory outside of a module.
+func configFilePath() string {
+	cwd, err := os.Getwd()
+
+	if err != nil {
+		panic(err)
+	}
+
+	if moduleRootPath := findModuleRoot(cwd); moduleRootPath != "" {
+		return filepath.Join(moduleRootPath, configFileName)
+	}
+
+	return filepath.Join(cwd, configFileName)
+}
+
+// Removes a comment and the quotes around a value in the config file
+func configValue(value string) string {
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = value[:index]
+	}
+
+	value = strings.TrimSpace(value)
+
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// Parses the config file, which is a YAML mapping of option names to values. Lists of values, either as [a, b] or
+// as items on the following lines, are joined with commas as for options such as --tags.
+func parseConfig(data string) (options map[string]string, names []string, errLine int) {
+	options = map[string]string{}
+	var listName string
+
+	for i, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") && listName != "" {
+			item := configValue(strings.TrimPrefix(trimmed, "- "))
+
+			if options[listName] == "" {
+				options[listName] = item
+			} else {
+				options[listName] += "," + item
+			}
+
+			continue
+		}
+
+		separator := strings.Index(trimmed, ":")
+
+		//Options cannot be indented, trailing whitespace such as a \r in files with CRLF line endings is allowed
+		if separator <= 0 || line[0] == ' ' || line[0] == '\t' {
+			return nil, nil, i + 1
+		}
+
+		name := strings.TrimSpace(trimmed[:separator])
+		value := configValue(trimmed[separator+1:])
+
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			var items []string
+
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = configValue(item); item != "" {
+					items = append(items, item)
+				}
+			}
+
+			value = strings.Join(items, ",")
+		}
+
+		//An empty value starts a list of items on the following lines
+		listName = ""
+
+		if value == "" {
+			listName = name
+		}
+
+		options[name] = value
+		names = append(names, name)
+	}
+
+	return options, names, 0
+}
+
+// Sets the options in the config file, if there is one, as the defaults for the options on the command line
+func applyConfig(flags *flag.FlagSet) {
+	path := configFilePath()
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	options, names, errLine := parseConfig(string(data))
+
+	if errLine > 0 {
+		usageError("Invalid line %d in %s, expected option: value", errLine, path)
+	}
+
+	for _, name := range names {
+		if name == "version" || flags.Lookup(name) == nil {
+			usageError("Unknown option %s in %s", name, path)
+		}
+
+		if err := flags.Set(name, options[name]); err != nil {
+			usageError("Invalid value for %s in %s : %s", name, path, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	. "github.com/claassen/gotest"
+	. "github.com/claassen/gotest/assert"
+)
+
+func Test() {
+	Describe("When parsing the config file", func() {
+
+		It("reads options and values", func() {
+			options, names, errLine := parseConfig("---\n# defaults\ntimeout: 30s # per test\nreporter: \"junit\"\n")
+
+			AssertThat(errLine).IsEqualTo(0)
+			AssertThat(names).IsEqualTo([]string{"timeout", "reporter"})
+			AssertThat(options).IsEqualTo(map[string]string{"timeout": "30s", "reporter": "junit"})
+		})
+
+		It("joins lists of values with commas", func() {
+			options, _, errLine := parseConfig("tags: [a, 'b']\nskip:\n  - slow\n  - flaky\n")
+
+			AssertThat(errLine).IsEqualTo(0)
+			AssertThat(options).IsEqualTo(map[string]string{"tags": "a,b", "skip": "slow,flaky"})
+		})
+
+		It("allows trailing whitespace and CRLF line endings", func() {
+			options, _, errLine := parseConfig("timeout: 30s \r\nreporter: junit\r\nskip:\r\n  - slow\r\n")
+
+			AssertThat(errLine).IsEqualTo(0)
+			AssertThat(options).IsEqualTo(map[string]string{"timeout": "30s", "reporter": "junit", "skip": "slow"})
+		})
+
+		It("rejects indented options and lines without a value", func() {
+			_, _, errLine := parseConfig("timeout: 30s\n  reporter: junit\n")
+			AssertThat(errLine).IsEqualTo(2)
+
+			_, _, errLine = parseConfig("timeout: 30s\n\ttags: a\n")
+			AssertThat(errLine).IsEqualTo(2)
+
+			_, _, errLine = parseConfig("timeout\n")
+			AssertThat(errLine).IsEqualTo(1)
+		})
+	})
+}
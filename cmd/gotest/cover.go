@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Import path the go command gives the test main package as it is built from a file rather than a directory
+const testMainImportPath = "command-line-arguments"
+
+// A block of statements in a coverage profile
+type coverBlock struct {
+	//File and position of the block in the form file.go:startLine.startCol,endLine.endCol
+	position string
+	numStmt  int
+	count    int
+}
+
+// Reports whether an import path matches a --coverpkg pattern, which is either an import path or an import path
+// followed by /... to match it and every package below it
+func matchImportPattern(pattern, importPath string) bool {
+	if strings.HasSuffix(pattern, "/...") {
+		prefix := strings.TrimSuffix(pattern, "/...")
+		return importPath == prefix || strings.HasPrefix(importPath, prefix+"/")
+	}
+
+	return importPath == pattern
+}
+
+// Turns a --coverpkg pattern given as a directory into an import path pattern
+func resolveCoverPattern(pattern string) string {
+	if !strings.HasPrefix(pattern, ".") && !filepath.IsAbs(pattern) {
+		return pattern
+	}
+
+	suffix := ""
+
+	if strings.HasSuffix(pattern, "/...") {
+		pattern = strings.TrimSuffix(pattern, "/...")
+		suffix = "/..."
+	}
+
+	absPath, err := filepath.Abs(pattern)
+
+	if err != nil {
+		panic(fmt.Sprintf("Invalid coverpkg pattern %s : %s", pattern, err))
+	}
+
+	return importPathForDir(absPath) + suffix
+}
+
+// Returns the import paths of the packages to instrument. The tests are run against copies of the packages under
+// test so the copies of the packages matching --coverpkg are instrumented along with the packages themselves.
+func coverPackages() []string {
+	var packages []string
+
+	if len(context.coverPkgs) == 0 {
+		for _, p := range context.testPackages {
+			packages = append(packages, p.testPackageFullName)
+		}
+
+		return packages
+	}
+
+	for _, pattern := range context.coverPkgs {
+		packages = append(packages, resolveCoverPattern(pattern))
+	}
+
+	patterns := packages
+
+	for _, p := range context.testPackages {
+		for _, pattern := range patterns {
+			if matchImportPattern(pattern, importPathForDir(p.originalPackagePath)) && !matchImportPattern(pattern, p.testPackageFullName) {
+				packages = append(packages, p.testPackageFullName)
+				break
+			}
+		}
+	}
+
+	return packages
+}
+
+// Creates the directory the instrumented test binary writes its coverage data to and returns the arguments which
+// make go build or go run instrument the packages to cover
+func startCoverage() []string {
+	if !context.cover {
+		return nil
+	}
+
+	context.coverDir = filepath.Join(context.workDir, "cover")
+
+	if err := os.MkdirAll(context.coverDir, os.ModePerm); err != nil {
+		panic(fmt.Sprintf("Error creating coverage directory: %s", err))
+	}
+
+	//No coverage data is written unless the main package is instrumented too
+	packages := append(coverPackages(), testMainImportPath)
+
+	return []string{"-cover", "-covermode=" + context.coverMode, "-coverpkg=" + strings.Join(packages, ",")}
+}
+
+// Returns the environment for running an instrumented test binary, or nil to inherit the environment unchanged
+func coverEnv() []string {
+	if !context.cover {
+		return nil
+	}
+
+	return append(os.Environ(), "GOCOVERDIR="+context.coverDir)
+}
+
+// Maps a file in a coverage profile from the copied test package back to the original package. Only the blocks of
+// the package's own source files are kept, test files are dropped as go test does.
+func originalCoverFile(file string) (string, bool) {
+	if file == context.testMainFilePath || strings.HasPrefix(file, testMainImportPath+"/") {
+		return "", false
+	}
+
+	for _, p := range context.testPackages {
+		if strings.HasPrefix(file, p.testPackageFullName+"/") {
+			name := path.Base(file)
+
+			if !isPackageSourceFile(p, name) {
+				return "", false
+			}
+
+			return importPathForDir(p.originalPackagePath) + "/" + name, true
+		}
+	}
+
+	return file, true
+}
+
+// Reports whether a file is one of the non test Go files of a package
+func isPackageSourceFile(p TestPackageInfo, name string) bool {
+	if strings.HasSuffix(name, "_test.go") || strings.HasSuffix(name, "_testx.go") {
+		return false
+	}
+
+	for _, fileName := range p.goFileNames {
+		if fileName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads a coverage profile, merging the blocks of copied test packages into those of the original packages
+func readCoverProfile(r io.Reader) (mode string, blocks []coverBlock) {
+	indexes := map[string]int{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "mode: ") {
+			mode = strings.TrimPrefix(line, "mode: ")
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		if len(fields) != 3 {
+			continue
+		}
+
+		separator := strings.LastIndex(fields[0], ":")
+
+		if separator < 0 {
+			continue
+		}
+
+		file, ok := originalCoverFile(fields[0][:separator])
+
+		if !ok {
+			continue
+		}
+
+		numStmt, _ := strconv.Atoi(fields[1])
+		count, _ := strconv.Atoi(fields[2])
+		block := coverBlock{position: file + fields[0][separator:], numStmt: numStmt, count: count}
+
+		index, exists := indexes[block.position]
+
+		if !exists {
+			indexes[block.position] = len(blocks)
+			blocks = append(blocks, block)
+		} else if mode == "set" {
+			if count > blocks[index].count {
+				blocks[index].count = count
+			}
+		} else {
+			blocks[index].count += count
+		}
+	}
+
+	return mode, blocks
+}
+
+func writeCoverProfile(filePath string, mode string, blocks []coverBlock) {
+	file, err := os.Create(filePath)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error creating coverage profile %s : %s", filePath, err))
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+
+	fmt.Fprintln(writer, "mode:", mode)
+
+	for _, block := range blocks {
+		fmt.Fprintln(writer, block.position, block.numStmt, block.count)
+	}
+
+	writer.Flush()
+}
+
+func coverPercent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return 100 * float64(covered) / float64(total)
+}
+
+// Prints the percentage of statements covered in each package and overall
+func printCoverage(out io.Writer, blocks []coverBlock) {
+	covered := map[string]int{}
+	total := map[string]int{}
+	var packages []string
+
+	for _, block := range blocks {
+		pkg := path.Dir(block.position[:strings.LastIndex(block.position, ":")])
+
+		if _, exists := total[pkg]; !exists {
+			packages = append(packages, pkg)
+		}
+
+		total[pkg] += block.numStmt
+
+		if block.count > 0 {
+			covered[pkg] += block.numStmt
+		}
+	}
+
+	sort.Strings(packages)
+
+	allCovered, allTotal := 0, 0
+
+	fmt.Fprintln(out, "-----------")
+	fmt.Fprintln(out, "Coverage:")
+
+	for _, pkg := range packages {
+		fmt.Fprintf(out, "%6.1f%%  %s\n", coverPercent(covered[pkg], total[pkg]), pkg)
+		allCovered += covered[pkg]
+		allTotal += total[pkg]
+	}
+
+	if allTotal == 0 {
+		fmt.Fprintln(out, "No statements to cover")
+		return
+	}
+
+	fmt.Fprintf(out, "%6.1f%%  of all statements\n", coverPercent(allCovered, allTotal))
+}
+
+// Converts the coverage data written by the test binary to a coverage profile and reports the coverage of each
+// package. Nothing is reported when the binary did not write any data, e.g. because the tests did not build.
+func reportCoverage() {
+	if !context.cover {
+		return
+	}
+
+	if files, err := ioutil.ReadDir(context.coverDir); err != nil || len(files) == 0 {
+		return
+	}
+
+	rawProfilePath := filepath.Join(context.coverDir, "raw.out")
+
+	convertCmd := exec.Command("go", "tool", "covdata", "textfmt", "-i="+context.coverDir, "-o="+rawProfilePath)
+	convertCmd.Stderr = os.Stderr
+
+	if err := convertCmd.Run(); err != nil {
+		panic(fmt.Sprintf("Error reading coverage data: %s", err))
+	}
+
+	rawProfile, err := os.Open(rawProfilePath)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error reading coverage data: %s", err))
+	}
+	defer rawProfile.Close()
+
+	mode, blocks := readCoverProfile(rawProfile)
+
+	if context.coverProfile != "" {
+		writeCoverProfile(context.coverProfile, mode, blocks)
+	}
+
+	//Keep machine readable results on stdout free of anything else
+	if context.reporter == "text" {
+		printCoverage(os.Stdout, blocks)
+	} else {
+		printCoverage(os.Stderr, blocks)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+
+	. "github.com/claassen/gotest"
+	. "github.com/claassen/gotest/assert"
+)
+
+func TestCoverProfile() {
+	Describe("When merging a coverage profile", func() {
+		var saved TestContext
+
+		BeforeEach(func() {
+			saved = context
+			context = TestContext{moduleRootPath: "/src/calc", modulePath: "example.com/calc"}
+			context.testPackages = []TestPackageInfo{{
+				originalPackagePath: "/src/calc",
+				testPackageFullName: "example.com/calc/__gotest/calc",
+				goFileNames:         []string{"calc.go", "calc_test.go", "calc_internal_test.go"},
+			}}
+		})
+
+		AfterEach(func() {
+			context = saved
+		})
+
+		It("keeps only the blocks of the package's source files", func() {
+			profile := "mode: set\n" +
+				"example.com/calc/__gotest/calc/calc.go:3.20,5.2 1 1\n" +
+				"example.com/calc/__gotest/calc/calc_test.go:8.14,10.2 2 1\n" +
+				"example.com/calc/__gotest/calc/calc_internal_testx.go:4.14,6.2 1 1\n" +
+				"example.com/calc/__gotest/calc/generated.go:1.1,2.2 1 1\n" +
+				"command-line-arguments/testmain.go:3.13,5.2 1 1\n"
+
+			mode, blocks := readCoverProfile(strings.NewReader(profile))
+
+			AssertThat(mode).IsEqualTo("set")
+			AssertThat(blocks).IsEqualTo([]coverBlock{{position: "example.com/calc/calc.go:3.20,5.2", numStmt: 1, count: 1}})
+		})
+	})
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Builds the tests and has the test binary list them in the given format without running any of them
+func listTests(format string) {
+	binaryPath := buildTests()
+
+	listCmd := exec.Command(binaryPath, append(context.testArgs, "-list="+format)...)
+	listCmd.Stdout = os.Stdout
+	listCmd.Stderr = os.Stderr
+
+	if err := runChild(listCmd); err != nil {
+		panic(fmt.Sprintf("Error listing tests: %s", err))
+	}
+}
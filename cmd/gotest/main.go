@@ -2,23 +2,54 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type TestContext struct {
 	rootPackageName     string
 	rootPackageFullPath string
+	modulePath          string
+	moduleRootPath      string
 	testPackages        []TestPackageInfo
 	testMainPackageDir  string
 	testMainFilePath    string
+	testArgs            []string
+	nodes               int
+	//Every directory searched for tests, including those without any
+	watchedDirs []string
+	//Directories of files read by the tests of a package, such as its testdata directory, and files embedded in a
+	//package, mapped to the directory of the package. They are only found when watching for changes.
+	dataDirs     map[string]string
+	embeds       map[string]string
+	reporter     string
+	cover        bool
+	coverMode    string
+	coverPkgs    []string
+	coverProfile string
+	//Directory the instrumented test binary writes its coverage data to
+	coverDir string
+	//Private directory the generated packages are written to and built in
+	workDir string
+	//Flags passed through to go build
+	buildArgs []string
+	//Number of times to run the tests
+	count int
 }
 
 type TestPackageInfo struct {
@@ -29,10 +60,48 @@ type TestPackageInfo struct {
 	testPackagePath     string
 	goFileNames         []string
 	testFuncNames       []string
+	//TestXxx functions written for the standard testing package
+	goTestNames []string
+	//Non Go files the package is built from
+	assetFileNames []string
+	//Set for the external test package of a package to the copy of the package it imports instead of the original
+	internalPackageFullName string
 }
 
+// Exit codes of the gotest command
+const (
+	exitTestsFailed = 1
+	exitBuildFailed = 2
+	exitUsage       = 3
+	//Any other error which stopped the tests from being run
+	exitOtherError = 4
+)
+
+// Error which ends the gotest command with the given exit code
+type exitError struct {
+	message string
+	code    int
+}
+
+func (e exitError) String() string {
+	return e.message
+}
+
+// Ends the gotest command with an error in the way it was called
+func usageError(format string, args ...interface{}) {
+	panic(exitError{fmt.Sprintf(format, args...), exitUsage})
+}
+
+// A directory to look for tests in, and in its sub-directories if recursive is set
+type packageDir struct {
+	path      string
+	recursive bool
+}
+
+// Import path of the gotest package, which the generated test main package imports
+const gotestImportPath = "github.com/claassen/gotest"
+
 var context = TestContext{}
-var goPath = ""
 
 func pathExists(path string) bool {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -42,8 +111,69 @@ func pathExists(path string) bool {
 	}
 }
 
+func isModuleMode() bool {
+	return context.moduleRootPath != ""
+}
+
+// Walks up from dir looking for the go.mod file of the enclosing module
+func findModuleRoot(dir string) string {
+	if os.Getenv("GO111MODULE") == "off" {
+		return ""
+	}
+
+	for {
+		if pathExists(filepath.Join(dir, "go.mod")) {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
+
+// Returns the argument of a single line directive of the go.mod file of a module, such as module or go, or an empty
+// string if there is none
+func readGoModDirective(moduleRootPath string, directive string) string {
+	goModPath := filepath.Join(moduleRootPath, "go.mod")
+
+	data, err := ioutil.ReadFile(goModPath)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error reading %s : %s", goModPath, err))
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+
+		fields := strings.Fields(line)
+
+		if len(fields) == 2 && fields[0] == directive {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+
+	return ""
+}
+
+func readModulePath(moduleRootPath string) string {
+	modulePath := readGoModDirective(moduleRootPath, "module")
+
+	if modulePath == "" {
+		panic(fmt.Sprintf("No module path found in %s", filepath.Join(moduleRootPath, "go.mod")))
+	}
+
+	return modulePath
+}
+
 func findPackagePath() bool {
-	goPaths := strings.Split(os.ExpandEnv("$GOPATH"), ":")
+	goPaths := filepath.SplitList(os.ExpandEnv("$GOPATH"))
 
 	foundPackage := false
 
@@ -52,7 +182,6 @@ func findPackagePath() bool {
 		packagePath := filepath.Join(filepath.Join(path, "src"), context.rootPackageName)
 
 		if pathExists(packagePath) {
-			goPath = path
 			context.rootPackageFullPath = packagePath
 			foundPackage = true
 			break
@@ -62,16 +191,165 @@ func findPackagePath() bool {
 	return foundPackage
 }
 
-func processDir(path string) {
+// Resolves a package pattern the way go test does. Patterns may be relative or absolute directories
+// ("./...", "./foo") or import paths ("example.com/mod/foo/..."). A trailing "/..." includes all
+// packages in sub-directories. In GOPATH mode a bare import path also includes sub-directories.
+func resolvePattern(pattern string) (path string, recursive bool) {
+	recursive = pattern == "..." || strings.HasSuffix(pattern, "/...")
+	pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+
+	if pattern == "" {
+		pattern = "."
+	}
+
+	isDir := pattern == "." || pattern == ".." || strings.HasPrefix(pattern, "./") ||
+		strings.HasPrefix(pattern, "../") || filepath.IsAbs(pattern)
+
+	if isDir {
+		absPath, err := filepath.Abs(pattern)
+
+		if err != nil {
+			usageError("Invalid package directory %s : %s", pattern, err)
+		}
+
+		if !pathExists(absPath) {
+			usageError("Could not find package: %s", pattern)
+		}
+
+		context.moduleRootPath = findModuleRoot(absPath)
+		context.rootPackageFullPath = absPath
+
+		return absPath, recursive
+	}
+
+	cwd, err := os.Getwd()
+
+	if err != nil {
+		panic(err)
+	}
+
+	context.moduleRootPath = findModuleRoot(cwd)
+
+	if !isModuleMode() {
+		context.rootPackageName = pattern
+
+		if !findPackagePath() {
+			usageError("Could not find package: %s", context.rootPackageName)
+		}
+
+		return context.rootPackageFullPath, true
+	}
+
+	modulePath := readModulePath(context.moduleRootPath)
+
+	if pattern != modulePath && !strings.HasPrefix(pattern, modulePath+"/") {
+		usageError("Package %s is not in the main module (%s)", pattern, modulePath)
+	}
+
+	path = filepath.Join(context.moduleRootPath, filepath.FromSlash(strings.TrimPrefix(pattern, modulePath)))
+
+	if !pathExists(path) {
+		usageError("Could not find package: %s", pattern)
+	}
+
+	context.rootPackageFullPath = path
+
+	return path, recursive
+}
+
+// Resolves the package patterns given on the command line, which must all be in the same module
+func resolvePatterns(patterns []string) []packageDir {
+	var dirs []packageDir
+	var moduleRootPath, rootPackageFullPath string
+
+	for i, pattern := range patterns {
+		path, recursive := resolvePattern(pattern)
+
+		if i == 0 {
+			moduleRootPath = context.moduleRootPath
+			rootPackageFullPath = context.rootPackageFullPath
+		} else if context.moduleRootPath != moduleRootPath {
+			usageError("Packages %s and %s are not in the same module", patterns[0], pattern)
+		}
+
+		dirs = append(dirs, packageDir{path: path, recursive: recursive})
+	}
+
+	//Outside of a module the test main package is generated in the first package
+	context.rootPackageFullPath = rootPackageFullPath
+
+	if isModuleMode() {
+		context.modulePath = readModulePath(context.moduleRootPath)
+	}
+
+	return dirs
+}
+
+// Returns the import path of the package in the given directory
+func importPathForDir(path string) string {
+	if isModuleMode() {
+		relPath, err := filepath.Rel(context.moduleRootPath, path)
+
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			panic(fmt.Sprintf("Directory %s is outside of module root %s", path, context.moduleRootPath))
+		}
+
+		if relPath == "." {
+			return context.modulePath
+		}
+
+		return context.modulePath + "/" + filepath.ToSlash(relPath)
+	}
+
+	for _, goPath := range filepath.SplitList(os.ExpandEnv("$GOPATH")) {
+		srcPath := filepath.Join(goPath, "src")
+
+		if relPath, err := filepath.Rel(srcPath, path); err == nil && !strings.HasPrefix(relPath, "..") {
+			return filepath.ToSlash(relPath)
+		}
+	}
+
+	panic(fmt.Sprintf("Directory %s is not inside a module or $GOPATH/src", path))
+}
+
+// Reports whether a directory name is one of the packages generated to run the tests
+func isGeneratedDir(name string) bool {
+	return strings.HasSuffix(name, "__test") || strings.HasSuffix(name, "__xtest") || name == "__testmain"
+}
+
+func processDirs(dirs []packageDir) {
+	for _, dir := range dirs {
+		processDir(dir.path, dir.recursive)
+	}
+}
+
+func processDir(path string, recursive bool) {
 	testPackageInfo := TestPackageInfo{}
 	testPackageInfo.originalPackageName = filepath.Base(path)
 	testPackageInfo.originalPackagePath = path
 	testPackageInfo.testPackageName = filepath.Base(path) + "__test"
-	testPackageInfo.testPackageFullName = strings.TrimLeft(strings.Replace(path, filepath.Join(goPath, "src"), "", 1)+"/"+testPackageInfo.testPackageName, "/")
-	testPackageInfo.testPackagePath = filepath.Join(testPackageInfo.originalPackagePath, testPackageInfo.testPackageName)
+	testPackageInfo.testPackageFullName = importPathForDir(path) + "/" + testPackageInfo.testPackageName
+
+	externalPackageInfo := TestPackageInfo{}
+	externalPackageInfo.originalPackageName = filepath.Base(path) + "_test"
+	externalPackageInfo.originalPackagePath = path
+	externalPackageInfo.testPackageName = filepath.Base(path) + "__xtest"
+	externalPackageInfo.testPackageFullName = importPathForDir(path) + "/" + externalPackageInfo.testPackageName
+	externalPackageInfo.internalPackageFullName = testPackageInfo.testPackageFullName
 
 	includePackage := false
 
+	//A directory matched by several patterns is only searched once, though its sub-directories may not have been
+	searched := false
+
+	for _, dir := range context.watchedDirs {
+		searched = searched || dir == path
+	}
+
+	if !searched {
+		context.watchedDirs = append(context.watchedDirs, path)
+	}
+
 	files, err := ioutil.ReadDir(path)
 
 	if err != nil {
@@ -80,136 +358,290 @@ func processDir(path string) {
 
 	for _, f := range files {
 		if f.IsDir() {
-			if f.Name() != "vendor" && f.Name() != ".git" && f.Name() != "Godeps" {
-				processDir(filepath.Join(path, f.Name()))
+			if !recursive || f.Name() == "vendor" || f.Name() == ".git" || f.Name() == "Godeps" || f.Name() == "testdata" {
+				continue
 			}
-		} else if filepath.Ext(f.Name()) == ".go" {
-			testPackageInfo.goFileNames = append(testPackageInfo.goFileNames, f.Name())
-
-			if strings.HasSuffix(f.Name(), "_test.go") {
-
-				//Read Test function names
-				fset := token.NewFileSet()
-				f, parseErr := parser.ParseFile(fset, filepath.Join(path, f.Name()), nil, 0)
-
-				if parseErr != nil {
-					panic(parseErr)
-				}
 
-				if f.Name.Name == "main" {
-					break
-				}
+			//Generated packages left behind by an earlier run
+			if isGeneratedDir(f.Name()) {
+				continue
+			}
 
-				includePackage = true
+			//Nested modules are not part of the current module
+			if isModuleMode() && pathExists(filepath.Join(path, f.Name(), "go.mod")) {
+				continue
+			}
 
-				for _, decl := range f.Decls {
-					//See if decl is a func decl
-					fnDecl, ok := decl.(*ast.FuncDecl)
+			processDir(filepath.Join(path, f.Name()), recursive)
+		} else if filepath.Ext(f.Name()) == ".go" {
+			//Files excluded by build constraints are not part of the package
+			if match, err := build.Default.MatchFile(path, f.Name()); err != nil {
+				panic(err)
+			} else if !match {
+				continue
+			}
 
-					if !ok {
-						continue
-					}
+			if !strings.HasSuffix(f.Name(), "_test.go") {
+				testPackageInfo.goFileNames = append(testPackageInfo.goFileNames, f.Name())
+				continue
+			}
 
-					//Skip instance function
-					if fnDecl.Recv != nil {
-						continue
-					}
+			//Read Test function names
+			fset := token.NewFileSet()
+			file, parseErr := parser.ParseFile(fset, filepath.Join(path, f.Name()), nil, 0)
 
-					if fnDecl.Type.Results != nil {
-						fmt.Println("Skipping func:", fnDecl.Name.String(), ". Test functions cannot have a return type.")
-						continue
-					}
+			if parseErr != nil {
+				panic(exitError{parseErr.Error(), exitBuildFailed})
+			}
 
-					if fnDecl.Type.Params.List != nil {
-						fmt.Println("Skipping func:", fnDecl.Name.String(), ". Test functions cannot have parameters.")
-						continue
-					}
+			includePackage = true
 
-					testPackageInfo.testFuncNames = append(testPackageInfo.testFuncNames, fnDecl.Name.String())
-				}
+			//Files of an external test package are copied to a package of their own
+			if strings.HasSuffix(file.Name.Name, "_test") {
+				externalPackageInfo.goFileNames = append(externalPackageInfo.goFileNames, f.Name())
+				collectTestFuncs(&externalPackageInfo, file)
+			} else {
+				testPackageInfo.goFileNames = append(testPackageInfo.goFileNames, f.Name())
+				collectTestFuncs(&testPackageInfo, file)
 			}
+		} else if assetExtensions[filepath.Ext(f.Name())] {
+			testPackageInfo.assetFileNames = append(testPackageInfo.assetFileNames, f.Name())
 		}
 	}
 
+	if searched {
+		return
+	}
+
 	if includePackage {
 		context.testPackages = append(context.testPackages, testPackageInfo)
 	}
+
+	if len(externalPackageInfo.goFileNames) > 0 {
+		context.testPackages = append(context.testPackages, externalPackageInfo)
+	}
 }
 
-func createTestPackages() {
-	for _, p := range context.testPackages {
+// Returns the name a file refers to an imported package by, which is the name of the package unless the import
+// renames it, or an empty string if the file does not import it
+func importName(file *ast.File, importPath string, packageName string) string {
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err != nil || path != importPath {
+			continue
+		}
 
-		if err := os.MkdirAll(p.testPackagePath, os.ModePerm); err != nil {
-			panic(fmt.Sprintf("Error creating temp test package path %s : %s", p.testPackagePath, err))
+		if spec.Name != nil {
+			return spec.Name.Name
 		}
 
-		for _, fileName := range p.goFileNames {
+		return packageName
+	}
 
-			originalFileFullPath := filepath.Join(p.originalPackagePath, fileName)
-			copiedFileFullPath := filepath.Join(p.testPackagePath, strings.Replace(fileName, "_test.go", "_testx.go", 1))
+	return ""
+}
 
-			originalFile, err1 := os.Open(originalFileFullPath)
-			if err1 != nil {
-				panic(fmt.Sprintf("Error opening file %s : %s", originalFileFullPath, err1))
-			}
-			defer originalFile.Close()
+// Reports whether a function calls RunSpecs, which only runs the gotest tests of its package with go test. The
+// gotest command runs those tests itself.
+func callsRunSpecs(fnDecl *ast.FuncDecl, gotestName string) bool {
+	found := false
 
-			copiedFile, err2 := os.Create(copiedFileFullPath)
-			if err2 != nil {
-				panic(fmt.Sprintf("Error creating file copy %s : %s", copiedFileFullPath, err2))
-			}
-			defer copiedFile.Close()
+	ast.Inspect(fnDecl.Body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+
+		if !ok {
+			return !found
+		}
+
+		switch fun := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			pkg, ok := fun.X.(*ast.Ident)
+			found = found || (ok && pkg.Name == gotestName && fun.Sel.Name == "RunSpecs")
+		case *ast.Ident:
+			found = found || (gotestName == "." && fun.Name == "RunSpecs")
+		}
 
-			originalFileScanner := bufio.NewScanner(originalFile)
-			copiedFileWriter := bufio.NewWriter(copiedFile)
+		return !found
+	})
 
-			for originalFileScanner.Scan() {
-				line := originalFileScanner.Text()
+	return found
+}
+
+// Reports whether a function is a test written for the standard testing package: TestXxx(t *testing.T)
+func isGoTest(fnDecl *ast.FuncDecl, testingName string) bool {
+	name := fnDecl.Name.Name
+
+	if !strings.HasPrefix(name, "Test") || fnDecl.Type.Results != nil {
+		return false
+	}
 
-				if strings.Contains(line, "package "+p.originalPackageName) {
-					line = "package " + p.testPackageName
-				}
+	if next, _ := utf8.DecodeRuneInString(name[len("Test"):]); unicode.IsLower(next) {
+		return false
+	}
+
+	params := fnDecl.Type.Params.List
+
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+
+	pointer, ok := params[0].Type.(*ast.StarExpr)
+
+	if !ok {
+		return false
+	}
+
+	switch paramType := pointer.X.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := paramType.X.(*ast.Ident)
+		return ok && pkg.Name == testingName && paramType.Sel.Name == "T"
+	case *ast.Ident:
+		return testingName == "." && paramType.Name == "T"
+	}
+
+	return false
+}
 
-				fmt.Fprintln(copiedFileWriter, line)
+// Records the test functions declared in a test file. Functions without parameters or results declare gotest
+// tests, and TestXxx(t *testing.T) functions are tests written for the standard testing package.
+func collectTestFuncs(p *TestPackageInfo, file *ast.File) {
+	testingName := importName(file, "testing", "testing")
+	gotestName := importName(file, gotestImportPath, "testing")
+
+	//Files written only for the standard testing package may declare helpers and examples without parameters
+	gotestFile := testingName == "" || gotestName != ""
+
+	for _, decl := range file.Decls {
+		//See if decl is a func decl
+		fnDecl, ok := decl.(*ast.FuncDecl)
+
+		if !ok {
+			continue
+		}
+
+		//Skip instance function
+		if fnDecl.Recv != nil {
+			continue
+		}
+
+		if testingName != "" && isGoTest(fnDecl, testingName) {
+			if gotestName != "" && callsRunSpecs(fnDecl, gotestName) {
+				continue
 			}
 
-			copiedFileWriter.Flush()
+			p.goTestNames = append(p.goTestNames, fnDecl.Name.String())
+			continue
+		}
+
+		if !gotestFile {
+			continue
+		}
+
+		if fnDecl.Type.Results != nil {
+			fmt.Fprintln(os.Stderr, "Skipping func:", fnDecl.Name.String(), ". Test functions cannot have a return type.")
+			continue
+		}
+
+		if fnDecl.Type.Params.List != nil {
+			fmt.Fprintln(os.Stderr, "Skipping func:", fnDecl.Name.String(), ". Test functions cannot have parameters.")
+			continue
 		}
+
+		p.testFuncNames = append(p.testFuncNames, fnDecl.Name.String())
 	}
 }
 
-func createTestMainPackage() {
-	context.testMainPackageDir = filepath.Join(context.rootPackageFullPath, "__testmain")
+func createTestPackages() {
+	for i := range context.testPackages {
+		p := &context.testPackages[i]
+		p.testPackagePath = generatedPackageDir(p.testPackageFullName)
+		assets := p.assetFileNames
 
-	if err := os.MkdirAll(context.testMainPackageDir, os.ModePerm); err != nil {
-		panic(fmt.Sprintf("Error creating __testmain directory: %s", err))
-	}
+		for _, fileName := range p.goFileNames {
+			copiedFileFullPath := filepath.Join(p.testPackagePath, strings.Replace(fileName, "_test.go", "_testx.go", 1))
+			embedPatterns := copyGoFile(*p, filepath.Join(p.originalPackagePath, fileName), copiedFileFullPath)
 
-	context.testMainFilePath = filepath.Join(context.testMainPackageDir, "testmain.go")
-	testMainFile, err := os.Create(context.testMainFilePath)
+			assets = append(assets, embeddedFiles(p.originalPackagePath, embedPatterns)...)
+		}
 
-	if err != nil {
-		panic(fmt.Sprintf("Error creating testmain.go: %s", err))
+		for _, asset := range assets {
+			copyGeneratedFile(filepath.Join(p.testPackagePath, asset), filepath.Join(p.originalPackagePath, asset))
+		}
+
+		if isModuleMode() {
+			createGeneratedModule(p.testPackagePath, p.testPackageFullName)
+		}
 	}
+}
+
+func createTestMainPackage() {
+	testMainPackageFullName := importPathForDir(context.rootPackageFullPath) + "/__testmain"
+	context.testMainPackageDir = generatedPackageDir(testMainPackageFullName)
+	context.testMainFilePath = filepath.Join(context.testMainPackageDir, "testmain.go")
+	testMainFile := createGeneratedFile(context.testMainFilePath)
 	defer testMainFile.Close()
 
 	testMainWriter := bufio.NewWriter(testMainFile)
 
+	//Packages without any tests of their own are only built to be imported by their external test package
+	var packages []TestPackageInfo
+	hasGoTests := false
+
+	for _, p := range context.testPackages {
+		if len(p.testFuncNames) > 0 || len(p.goTestNames) > 0 {
+			packages = append(packages, p)
+		}
+
+		if len(p.goTestNames) > 0 {
+			hasGoTests = true
+		}
+	}
+
 	fmt.Fprintln(testMainWriter, "package main")
 	fmt.Fprintln(testMainWriter, "import(")
-	fmt.Fprintln(testMainWriter, "\"github.com/claassen/gotest\"")
+	fmt.Fprintln(testMainWriter, strconv.Quote(gotestImportPath))
 
-	for _, p := range context.testPackages {
-		fmt.Fprintln(testMainWriter, "\""+p.testPackageFullName+"\"")
+	if hasGoTests {
+		fmt.Fprintln(testMainWriter, "gotesting \"testing\"")
+	}
+
+	//Packages in directories with the same name are told apart by their position
+	names := make([]string, len(packages))
+	used := map[string]bool{}
+
+	for i, p := range packages {
+		names[i] = p.testPackageName
+
+		if used[names[i]] {
+			names[i] += strconv.Itoa(i)
+		}
+
+		used[names[i]] = true
+		fmt.Fprintf(testMainWriter, "%s %q\n", names[i], p.testPackageFullName)
 	}
 
 	fmt.Fprintln(testMainWriter, ")")
 	fmt.Fprintln(testMainWriter, "func main() {")
 
-	for _, p := range context.testPackages {
+	for i, p := range packages {
+		//The tests of each package run in its directory
+		fmt.Fprintf(testMainWriter, "testing.SetPackage(%q, %q)\n", importPathForDir(p.originalPackagePath), p.originalPackagePath)
+
 		for _, fn := range p.testFuncNames {
-			fmt.Fprintln(testMainWriter, p.testPackageName+"."+fn+"()")
+			fmt.Fprintln(testMainWriter, names[i]+"."+fn+"()")
+		}
+
+		if len(p.goTestNames) == 0 {
+			continue
 		}
+
+		//Named after the package rather than its import path, as slashes separate subtests in go test style names
+		fmt.Fprintf(testMainWriter, "testing.GoTests(%q, []gotesting.InternalTest{\n", p.originalPackageName)
+
+		for _, fn := range p.goTestNames {
+			fmt.Fprintf(testMainWriter, "{Name: %q, F: %s.%s},\n", fn, names[i], fn)
+		}
+
+		fmt.Fprintln(testMainWriter, "})")
 	}
 
 	fmt.Fprintln(testMainWriter, "testing.RunTests()")
@@ -217,23 +649,78 @@ func createTestMainPackage() {
 	fmt.Fprintln(testMainWriter, "}")
 
 	testMainWriter.Flush()
+
+	if isModuleMode() {
+		createGeneratedModule(context.testMainPackageDir, testMainPackageFullName)
+		writeWorkspace()
+	} else {
+		linkSourceDirs(filepath.Join(context.workDir, "src"))
+	}
 }
 
-func runTests() {
-	runCmd := exec.Command("go", "run", context.testMainFilePath)
+// Builds the test binary in the work directory and returns its path
+func buildTests() string {
+	binaryPath := filepath.Join(context.workDir, "testmain")
+
+	if runtime.GOOS == "windows" {
+		binaryPath += ".exe"
+	}
+
+	buildArgs := append([]string{"build", "-o", binaryPath}, context.buildArgs...)
+	buildArgs = append(buildArgs, startCoverage()...)
+	buildCmd := exec.Command("go", append(buildArgs, context.testMainFilePath)...)
+	buildCmd.Stdout = os.Stdout
+	buildCmd.Stderr = os.Stderr
+	buildCmd.Env = buildEnv()
+
+	if err := runChild(buildCmd); err != nil {
+		panic(exitError{fmt.Sprintf("Build failed: %s", err), exitBuildFailed})
+	}
+
+	return binaryPath
+}
+
+func runTestBinary(binaryPath string) error {
+	runCmd := exec.Command(binaryPath, context.testArgs...)
 	runCmd.Stdout = os.Stdout
 	runCmd.Stderr = os.Stderr
+	runCmd.Env = coverEnv()
+
+	return runChild(runCmd)
+}
+
+// Builds the test binary once and runs the tests --count times, all of them even if some fail
+func runTests() {
+	binaryPath := buildTests()
+
+	var err error
 
-	if err := runCmd.Run(); err != nil {
-		panic (err)
+	for i := 0; i < context.count; i++ {
+		var runErr error
+
+		if context.nodes > 1 {
+			runErr = runTestsInParallel(binaryPath)
+		} else {
+			runErr = runTestBinary(binaryPath)
+		}
+
+		if runErr != nil {
+			err = runErr
+		}
+	}
+
+	//Coverage is reported for failed runs too
+	reportCoverage()
+
+	if err != nil {
+		panic(exitError{fmt.Sprintf("Tests failed: %s", err), exitTestsFailed})
 	}
 }
 
 func cleanup() {
-	os.RemoveAll(context.testMainPackageDir)
-
-	for _, p := range context.testPackages {
-		os.RemoveAll(p.testPackagePath)
+	if context.workDir != "" {
+		os.RemoveAll(context.workDir)
+		context.workDir = ""
 	}
 }
 
@@ -245,26 +732,228 @@ func main() {
 		err := recover()
 
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fmt.Fprintln(os.Stderr, err)
+
+			if exitErr, ok := err.(exitError); ok {
+				os.Exit(exitErr.code)
+			}
+
+			os.Exit(exitOtherError)
 		}
 	}()
 
-	if len(os.Args) != 2 {
-		fmt.Println("Usage: gotest <package name>")
+	flags := flag.NewFlagSet("gotest", flag.ContinueOnError)
+	focus := flags.String("focus", "", "only run tests whose full description matches this regular expression")
+	skip := flags.String("skip", "", "skip tests whose full description matches this regular expression")
+	reporter := flags.String("reporter", "text", "format of the test results: text, junit, tap or json")
+	output := flags.String("output", "", "file to write the test results to instead of stdout")
+	slowThreshold := flags.Duration("slow-threshold", 0, "list the tests which take longer than this duration, e.g. 500ms")
+	slowCount := flags.Int("slow-count", 10, "maximum number of slow tests to list")
+	timeout := flags.Duration("timeout", 0, "fail tests which take longer than this duration, e.g. 30s")
+	randomize := flags.Bool("randomize", false, "run top level Describe and It blocks in a random order")
+	randomizeAll := flags.Bool("randomize-all", false, "run the blocks at every level in a random order")
+	seed := flags.Int64("seed", 0, "seed used to randomize the order of the tests, to repeat a previous run")
+	flags.IntVar(&context.nodes, "p", 1, "number of processes to split the tests across")
+	flags.IntVar(&context.nodes, "nodes", 1, "number of processes to split the tests across (same as -p)")
+	flags.BoolVar(&context.cover, "cover", false, "report the percentage of statements covered by the tests in each package")
+	flags.StringVar(&context.coverMode, "covermode", "", "coverage mode: set, count or atomic (default set, or atomic with --race)")
+	coverPkg := flags.String("coverpkg", "", "comma separated list of package patterns to report coverage for, instead of the packages under test")
+	coverProfile := flags.String("coverprofile", "", "file to write a coverage profile to, for use with go tool cover")
+	race := flags.Bool("race", false, "build the tests with the data race detector")
+	tags := flags.String("tags", "", "comma separated list of build tags to build the tests with")
+	gcflags := flags.String("gcflags", "", "flags passed to go tool compile, as with go build")
+	ldflags := flags.String("ldflags", "", "flags passed to go tool link, as with go build")
+	mod := flags.String("mod", "", "module download mode passed to go build: readonly")
+	trimpath := flags.Bool("trimpath", false, "remove file system paths from the test binary, as with go build")
+	flags.IntVar(&context.count, "count", 1, "number of times to run the tests")
+	listFormat := flags.String("format", "tree", "format of the list command: tree or json")
+	showVersion := flags.Bool("version", false, "print the version of gotest and exit")
+
+	//Options on the command line override those in the config file
+	applyConfig(flags)
+
+	command, patterns := parseCommandLine(flags, os.Args[1:])
+
+	if *showVersion {
+		fmt.Println("gotest", gotestVersion())
+		return
+	}
+
+	if len(patterns) == 0 {
+		if command == "clean" {
+			patterns = []string{"./..."}
+		} else {
+			patterns = []string{"."}
+		}
+	}
+
+	switch *reporter {
+	case "text", "junit", "tap", "json":
+		context.reporter = *reporter
+		context.testArgs = append(context.testArgs, "-reporter="+*reporter)
+	default:
+		usageError("Unknown reporter %s, must be one of text, junit, tap or json", *reporter)
+	}
+
+	//The tests run in the directories of their packages so the output path must not be relative
+	if *output != "" {
+		outputPath, err := filepath.Abs(*output)
+
+		if err != nil {
+			usageError("Invalid output file %s : %s", *output, err)
+		}
+
+		context.testArgs = append(context.testArgs, "-output="+outputPath)
 	}
 
-	context.rootPackageName = os.Args[1]
+	filters := []struct{ name, expr string }{{"focus", *focus}, {"skip", *skip}}
 
-	if !findPackagePath() {
-		panic(fmt.Sprintf("Could not find package: %s", context.rootPackageName))
+	for _, filter := range filters {
+		if filter.expr == "" {
+			continue
+		}
+
+		if _, err := regexp.Compile(filter.expr); err != nil {
+			usageError("Invalid %s regular expression: %s", filter.name, err)
+		}
+
+		context.testArgs = append(context.testArgs, "-"+filter.name+"="+filter.expr)
 	}
 
-	processDir(context.rootPackageFullPath)
+	if *slowThreshold > 0 {
+		context.testArgs = append(context.testArgs, "-slow-threshold="+slowThreshold.String(), fmt.Sprintf("-slow-count=%d", *slowCount))
+	}
+
+	if context.nodes < 1 {
+		usageError("Invalid number of nodes: %d", context.nodes)
+	}
+
+	if *seed != 0 && !*randomize && !*randomizeAll {
+		usageError("--seed requires --randomize or --randomize-all")
+	}
+
+	//The seed is chosen here so that every node shuffles the tests in the same way
+	if *randomize || *randomizeAll {
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+		}
+
+		if *randomizeAll {
+			context.testArgs = append(context.testArgs, "-randomize-all")
+		} else {
+			context.testArgs = append(context.testArgs, "-randomize")
+		}
+
+		context.testArgs = append(context.testArgs, fmt.Sprintf("-seed=%d", *seed))
+	}
+
+	if *timeout > 0 {
+		context.testArgs = append(context.testArgs, "-timeout="+timeout.String())
+	}
+
+	if context.count < 1 {
+		usageError("Invalid count: %d", context.count)
+	}
+
+	if *race {
+		context.buildArgs = append(context.buildArgs, "-race")
+	}
+
+	if *tags != "" {
+		context.buildArgs = append(context.buildArgs, "-tags="+*tags)
+		//Files are selected with the same build tags as the tests are built with
+		build.Default.BuildTags = strings.Split(*tags, ",")
+	}
+
+	if *gcflags != "" {
+		context.buildArgs = append(context.buildArgs, "-gcflags="+*gcflags)
+	}
+
+	if *ldflags != "" {
+		context.buildArgs = append(context.buildArgs, "-ldflags="+*ldflags)
+	}
+
+	//The tests are built in a workspace, where the go command does not update go.mod or use vendor directories
+	if *mod != "" && *mod != "readonly" {
+		usageError("Unsupported module download mode %s, only readonly is supported", *mod)
+	}
+
+	if *mod != "" {
+		context.buildArgs = append(context.buildArgs, "-mod="+*mod)
+	}
+
+	if *trimpath {
+		context.buildArgs = append(context.buildArgs, "-trimpath")
+	}
+
+	//Coverage counters are updated from several goroutines when looking for data races
+	if context.coverMode == "" {
+		if *race {
+			context.coverMode = "atomic"
+		} else {
+			context.coverMode = "set"
+		}
+	}
+
+	switch context.coverMode {
+	case "set", "count", "atomic":
+	default:
+		usageError("Unknown cover mode %s, must be one of set, count or atomic", context.coverMode)
+	}
+
+	if *listFormat != "tree" && *listFormat != "json" {
+		usageError("Unknown list format %s, must be tree or json", *listFormat)
+	}
+
+	if *coverPkg != "" {
+		context.cover = true
+		context.coverPkgs = strings.Split(*coverPkg, ",")
+	}
+
+	if *coverProfile != "" {
+		context.cover = true
+		coverProfilePath, err := filepath.Abs(*coverProfile)
+
+		if err != nil {
+			usageError("Invalid coverage profile %s : %s", *coverProfile, err)
+		}
+
+		context.coverProfile = coverProfilePath
+	}
+
+	dirs := resolvePatterns(patterns)
+
+	if command == "clean" {
+		for _, dir := range dirs {
+			removeGeneratedDirs(dir.path, dir.recursive)
+		}
+
+		return
+	}
+
+	handleSignals()
+
+	if command == "watch" {
+		watch(dirs)
+		return
+	}
+
+	//Listing the tests does not run them, so there is nothing to cover
+	if command == "list" {
+		context.cover = false
+	}
+
+	processDirs(dirs)
+
+	createWorkDir()
 
 	createTestPackages()
 
 	createTestMainPackage()
 
-	runTests()
+	if command == "list" {
+		listTests(*listFormat)
+	} else {
+		runTests()
+	}
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"github.com/claassen/gotest/internal/nodesync"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Makes sure that nodes waiting on a node which has exited do not wait forever
+func nodeExited(node int, syncDir string) {
+	writeIfMissing := func(name string, data string) {
+		path := filepath.Join(syncDir, name)
+
+		if !pathExists(path) {
+			ioutil.WriteFile(path, []byte(data), 0644)
+		}
+	}
+
+	writeIfMissing(nodesync.NodeDoneFilePrefix+strconv.Itoa(node), "")
+
+	if node == 1 && !pathExists(filepath.Join(syncDir, nodesync.BeforeSuiteDataFile)) {
+		writeIfMissing(nodesync.BeforeSuiteFailedFile, "The primary node exited before SynchronizedBeforeSuite finished")
+	}
+}
+
+// Runs the tests split across several copies of the test binary. The results of all nodes are then merged and
+// reported by the test binary, which fails if any of the tests failed.
+func runTestsInParallel(binaryPath string) error {
+	syncDir := filepath.Join(context.workDir, "sync")
+
+	//Files left by a previous run would release the nodes before the suite hooks have run
+	if err := os.RemoveAll(syncDir); err != nil {
+		panic(fmt.Sprintf("Error clearing sync directory: %s", err))
+	}
+
+	if err := os.MkdirAll(syncDir, os.ModePerm); err != nil {
+		panic(fmt.Sprintf("Error creating sync directory: %s", err))
+	}
+	nodeOutputPaths := make([]string, context.nodes)
+
+	var wg sync.WaitGroup
+
+	for node := 1; node <= context.nodes; node++ {
+		nodeOutputPaths[node-1] = filepath.Join(context.workDir, fmt.Sprintf("node-%d.json", node))
+
+		args := append(append([]string{}, context.testArgs...),
+			"-node="+strconv.Itoa(node),
+			"-nodes="+strconv.Itoa(context.nodes),
+			"-node-output="+nodeOutputPaths[node-1],
+			"-sync-dir="+syncDir)
+
+		nodeCmd := exec.Command(binaryPath, args...)
+		nodeCmd.Stdout = os.Stdout
+		nodeCmd.Stderr = os.Stderr
+		nodeCmd.Env = coverEnv()
+
+		if err := startChild(nodeCmd); err != nil {
+			panic(fmt.Sprintf("Error starting node %d: %s", node, err))
+		}
+
+		wg.Add(1)
+
+		go func(node int) {
+			defer wg.Done()
+
+			//Failing tests are expected to give a non zero exit code, the results are checked when merging
+			waitChild(nodeCmd)
+			nodeExited(node, syncDir)
+		}(node)
+	}
+
+	wg.Wait()
+
+	mergeCmd := exec.Command(binaryPath, append(context.testArgs, "-merge="+strings.Join(nodeOutputPaths, ","))...)
+	mergeCmd.Stdout = os.Stdout
+	mergeCmd.Stderr = os.Stderr
+	mergeCmd.Env = coverEnv()
+
+	return runChild(mergeCmd)
+}
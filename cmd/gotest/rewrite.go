@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Extensions of the non Go files which the go command builds into a package, such as assembly and cgo sources
+var assetExtensions = map[string]bool{
+	".s": true, ".S": true, ".sx": true,
+	".c": true, ".h": true, ".cc": true, ".cpp": true, ".cxx": true, ".hh": true, ".hpp": true, ".hxx": true,
+	".m": true, ".f": true, ".F": true, ".for": true, ".f90": true, ".syso": true,
+}
+
+// Copies a Go source file into a generated test package and returns the patterns of its //go:embed directives.
+// Only the package clause is changed. //line directives map positions in the copy back to the original file so
+// that compiler errors and stack traces point at the original source.
+func copyGoFile(p TestPackageInfo, originalPath string, copiedPath string) (embedPatterns []string) {
+	src, err := ioutil.ReadFile(originalPath)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error reading file %s : %s", originalPath, err))
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, originalPath, src, parser.ParseComments)
+
+	if err != nil {
+		panic(err)
+	}
+
+	//External test packages import the copy of the package under test, which includes its internal test files
+	if p.internalPackageFullName != "" {
+		for _, spec := range f.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path != importPathForDir(p.originalPackagePath) {
+				continue
+			}
+
+			if spec.Name == nil {
+				spec.Name = ast.NewIdent(strings.TrimSuffix(f.Name.Name, "_test"))
+			}
+
+			spec.Path.Value = strconv.Quote(p.internalPackageFullName)
+		}
+	}
+
+	f.Name.Name = p.testPackageName
+
+	//Everything before the line of the package clause, such as build constraints and the package comment, is
+	//copied as is so that it keeps its line numbers. The rest is printed from the AST.
+	file := fset.File(f.Package)
+	headerEnd := file.Offset(file.LineStart(file.Line(f.Package)))
+	var comments []*ast.CommentGroup
+
+	for _, group := range f.Comments {
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, "//go:embed ") {
+				embedPatterns = append(embedPatterns, parseEmbedPatterns(strings.TrimPrefix(comment.Text, "//go:embed "))...)
+			}
+		}
+
+		if group.Pos() > f.Package {
+			comments = append(comments, group)
+		}
+	}
+
+	f.Comments = comments
+	f.Doc = nil
+
+	var copied bytes.Buffer
+	copied.Write(src[:headerEnd])
+
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent | printer.SourcePos, Tabwidth: 8}
+
+	if err := config.Fprint(&copied, fset, f); err != nil {
+		panic(fmt.Sprintf("Error copying file %s : %s", originalPath, err))
+	}
+
+	copiedFile := createGeneratedFile(copiedPath)
+	defer copiedFile.Close()
+
+	if _, err := copiedFile.Write(copied.Bytes()); err != nil {
+		panic(fmt.Sprintf("Error writing file %s : %s", copiedPath, err))
+	}
+
+	return embedPatterns
+}
+
+// Splits the arguments of a //go:embed directive into patterns, which may be quoted if they contain spaces
+func parseEmbedPatterns(args string) []string {
+	var patterns []string
+
+	for args = strings.TrimSpace(args); args != ""; args = strings.TrimSpace(args) {
+		end := strings.IndexAny(args, " \t")
+
+		if args[0] == '"' || args[0] == '`' {
+			end = strings.IndexByte(args[1:], args[0]) + 2
+		}
+
+		if end <= 0 || end > len(args) {
+			end = len(args)
+		}
+
+		pattern := args[:end]
+
+		if unquoted, err := strconv.Unquote(pattern); err == nil {
+			pattern = unquoted
+		}
+
+		patterns = append(patterns, pattern)
+		args = args[end:]
+	}
+
+	return patterns
+}
+
+// Returns the files matched by //go:embed patterns, relative to the package directory
+func embeddedFiles(dir string, patterns []string) []string {
+	var files []string
+
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(pattern, "all:"))))
+
+		for _, match := range matches {
+			relPath, _ := filepath.Rel(dir, match)
+			files = append(files, filesBelow(dir, relPath)...)
+		}
+	}
+
+	return files
+}
+
+// Returns the path of a file, or of every file in a directory and its sub-directories, relative to dir
+func filesBelow(dir string, relPath string) []string {
+	var files []string
+
+	filepath.Walk(filepath.Join(dir, relPath), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+
+	return files
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time to wait after a change for further changes before rerunning the tests, so that saving several files at
+// once only causes a single run
+const watchDebounce = 300 * time.Millisecond
+
+// Files describing the module, a change to which can affect every package
+var moduleFileNames = map[string]bool{"go.mod": true, "go.sum": true, "go.work": true, "go.work.sum": true}
+
+// A file or directory which was created, changed or removed in a watched directory
+type fileChange struct {
+	path  string
+	isDir bool
+}
+
+// Reports whether a file is one of the hidden backup and lock files editors create next to the files being edited
+func isEditorFile(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// Returns the directory of the package a change affects, or all if it affects every package. Besides Go files,
+// the files built into a package, embedded in it or found in its testdata directory affect it.
+func changedPackageDir(change fileChange) (dir string, all bool, ok bool) {
+	dir, name := filepath.Dir(change.path), filepath.Base(change.path)
+
+	if packageDir, found := context.dataDirs[dir]; found {
+		return packageDir, false, true
+	}
+
+	if packageDir, found := context.embeds[change.path]; found {
+		return packageDir, false, true
+	}
+
+	if change.isDir {
+		return dir, false, !isGeneratedDir(name)
+	}
+
+	if moduleFileNames[name] {
+		return "", true, true
+	}
+
+	return dir, false, filepath.Ext(name) == ".go" || assetExtensions[filepath.Ext(name)]
+}
+
+// Waits for a change which can affect the tests and then for the changes to stop, and returns the directories of
+// the packages which changed, or nil if every package is affected
+func (w *dirWatcher) waitForChanges() map[string]bool {
+	changedDirs := map[string]bool{}
+	all := false
+
+	add := func(change fileChange) {
+		if dir, changesAll, ok := changedPackageDir(change); ok {
+			changedDirs[dir] = true
+			all = all || changesAll
+		}
+	}
+
+	for len(changedDirs) == 0 {
+		add(<-w.changes)
+	}
+
+	for {
+		select {
+		case change := <-w.changes:
+			add(change)
+		case <-time.After(watchDebounce):
+			if all {
+				return nil
+			}
+
+			return changedDirs
+		}
+	}
+}
+
+// Discards the changes made so far, such as those made while the tests ran. Tests can write to files in the
+// watched directories, e.g. to their testdata directories, and reporting those changes would rerun them forever.
+func (w *dirWatcher) ignoreChanges() {
+	done := make(chan struct{})
+	requests := w.ignoreRequests
+
+	//The watcher may be waiting to report changes, so they are discarded until it has answered
+	for {
+		select {
+		case requests <- done:
+			requests = nil
+		case <-w.changes:
+		case <-done:
+			return
+		}
+	}
+}
+
+// Empties a channel of changes without waiting for more
+func drainChanges(changes chan fileChange) {
+	for {
+		select {
+		case <-changes:
+		default:
+			return
+		}
+	}
+}
+
+// Finds the files read by the tests of the packages in the watched directories besides their sources: those in
+// their testdata directories and those they embed. Embedded directories are treated like testdata directories so
+// that files added to them are noticed.
+func findDataFiles() {
+	context.dataDirs = map[string]string{}
+	context.embeds = map[string]string{}
+
+	addDataDir := func(dir string, packageDir string) {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				context.dataDirs[path] = packageDir
+			}
+
+			return nil
+		})
+	}
+
+	for _, dir := range context.watchedDirs {
+		if testdata := filepath.Join(dir, "testdata"); pathExists(testdata) {
+			addDataDir(testdata, dir)
+		}
+
+		files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+
+		for _, file := range files {
+			for _, pattern := range embedPatternsOf(file) {
+				matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(pattern, "all:"))))
+
+				for _, match := range matches {
+					if info, err := os.Stat(match); err == nil && info.IsDir() {
+						addDataDir(match, dir)
+					} else {
+						context.embeds[match] = dir
+					}
+				}
+			}
+		}
+	}
+}
+
+// Returns the patterns of the //go:embed directives in a Go file
+func embedPatternsOf(path string) []string {
+	src, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil
+	}
+
+	var patterns []string
+
+	for _, line := range strings.Split(string(src), "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "//go:embed ") {
+			patterns = append(patterns, parseEmbedPatterns(strings.TrimPrefix(line, "//go:embed "))...)
+		}
+	}
+
+	return patterns
+}
+
+// Maps the import path of every package imported from the given directories to the import paths of the
+// packages which import it
+func findImporters(dirs []string) map[string][]string {
+	importers := map[string][]string{}
+
+	for _, dir := range dirs {
+		importPath := importPathForDir(dir)
+		imported := map[string]bool{}
+		files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+
+		for _, file := range files {
+			f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+
+			//Files which are being edited may not parse, building the tests will report the error
+			if err != nil {
+				continue
+			}
+
+			for _, spec := range f.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+
+				if err != nil || imported[path] {
+					continue
+				}
+
+				imported[path] = true
+				importers[path] = append(importers[path], importPath)
+			}
+		}
+	}
+
+	return importers
+}
+
+// Returns the test packages in the changed directories and those which import them, directly or indirectly
+func affectedPackages(changedDirs map[string]bool) []TestPackageInfo {
+	importers := findImporters(context.watchedDirs)
+	affected := map[string]bool{}
+	var pending []string
+
+	for dir := range changedDirs {
+		pending = append(pending, importPathForDir(dir))
+	}
+
+	for len(pending) > 0 {
+		path := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if affected[path] {
+			continue
+		}
+
+		affected[path] = true
+		pending = append(pending, importers[path]...)
+	}
+
+	var packages []TestPackageInfo
+
+	for _, p := range context.testPackages {
+		if affected[importPathForDir(p.originalPackagePath)] {
+			packages = append(packages, p)
+		}
+	}
+
+	return packages
+}
+
+func clearScreen() {
+	fmt.Print("\033[H\033[2J")
+}
+
+// Runs the tests affected by changes to the given directories, or all tests on the first run when there are no
+// changes, and returns a line describing the result. Errors are reported rather than ending the watch.
+func watchRun(watcher *dirWatcher, dirs []packageDir, changedDirs map[string]bool) (status string) {
+	context.testPackages = nil
+	context.watchedDirs = nil
+	context.dataDirs = nil
+	context.embeds = nil
+
+	defer func() {
+		cleanup()
+
+		//Directories are watched even when finding the tests failed so that fixing the error causes a rerun
+		dirsToWatch := append([]string{}, context.watchedDirs...)
+
+		for dir := range context.dataDirs {
+			dirsToWatch = append(dirsToWatch, dir)
+		}
+
+		for file := range context.embeds {
+			dirsToWatch = append(dirsToWatch, filepath.Dir(file))
+		}
+
+		if context.moduleRootPath != "" {
+			dirsToWatch = append(dirsToWatch, context.moduleRootPath)
+		}
+
+		for _, dir := range dirsToWatch {
+			if err := watcher.add(dir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error watching %s : %s\n", dir, err)
+			}
+		}
+
+		if err := recover(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = color.RedString("FAILED")
+		}
+
+		status = time.Now().Format("15:04:05") + " " + status
+	}()
+
+	processDirs(dirs)
+	findDataFiles()
+
+	total := len(context.testPackages)
+
+	if changedDirs != nil {
+		context.testPackages = affectedPackages(changedDirs)
+	}
+
+	if len(context.testPackages) == 0 {
+		return "No tests to run"
+	}
+
+	createWorkDir()
+
+	createTestPackages()
+
+	createTestMainPackage()
+
+	runTests()
+
+	return fmt.Sprintf("%s (%d of %d packages run)", color.GreenString("PASSED"), len(context.testPackages), total)
+}
+
+// Runs the tests and then reruns the affected tests every time a file changes. Only returns if watching fails.
+func watch(dirs []packageDir) {
+	watcher, err := newDirWatcher()
+
+	if err != nil {
+		panic(fmt.Sprintf("Error watching for changes: %s", err))
+	}
+
+	var changedDirs map[string]bool
+
+	for {
+		clearScreen()
+
+		status := watchRun(watcher, dirs, changedDirs)
+
+		fmt.Println("-----------")
+		fmt.Println(status, "- watching for changes, press Ctrl+C to stop")
+
+		watcher.ignoreChanges()
+		changedDirs = watcher.waitForChanges()
+	}
+}
@@ -0,0 +1,108 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"golang.org/x/sys/unix"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Watches directories for changes using inotify
+type dirWatcher struct {
+	fd      int
+	changes chan fileChange
+	//Requests to ignore the changes made so far, see ignoreChanges
+	ignoreRequests chan chan struct{}
+	mutex          sync.Mutex
+	//Watched directories by inotify watch descriptor
+	dirs map[int]string
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &dirWatcher{fd: fd, changes: make(chan fileChange, 100), ignoreRequests: make(chan chan struct{}), dirs: map[int]string{}}
+	go w.readEvents()
+
+	return w, nil
+}
+
+// Starts watching a directory. Adding a directory which is already watched has no effect.
+func (w *dirWatcher) add(dir string) error {
+	wd, err := unix.InotifyAddWatch(w.fd, dir, unix.IN_CLOSE_WRITE|unix.IN_CREATE|unix.IN_DELETE|unix.IN_MOVED_FROM|unix.IN_MOVED_TO)
+
+	if err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	w.dirs[wd] = dir
+	w.mutex.Unlock()
+
+	return nil
+}
+
+// Reads events as they are queued. A request to ignore the changes made so far is answered once every event queued
+// before it has been read and discarded.
+func (w *dirWatcher) readEvents() {
+	buffer := make([]byte, 64*1024)
+	var ignoring []chan struct{}
+
+	for {
+		n, err := unix.Read(w.fd, buffer)
+
+		if err == unix.EINTR {
+			continue
+		}
+
+		if err == unix.EAGAIN {
+			for _, done := range ignoring {
+				drainChanges(w.changes)
+				close(done)
+			}
+
+			ignoring = nil
+
+			select {
+			case done := <-w.ignoreRequests:
+				ignoring = append(ignoring, done)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			w.mutex.Lock()
+			dir, ok := w.dirs[int(event.Wd)]
+
+			//The watch is removed once the directory is deleted
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, int(event.Wd))
+			}
+			w.mutex.Unlock()
+
+			if ok && name != "" && !isEditorFile(name) && len(ignoring) == 0 {
+				w.changes <- fileChange{path: filepath.Join(dir, name), isDir: event.Mask&unix.IN_ISDIR != 0}
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Watches directories for changes by regularly comparing the modification times of their files
+type dirWatcher struct {
+	changes chan fileChange
+	//Requests to ignore the changes made so far, see ignoreChanges
+	ignoreRequests chan chan struct{}
+	mutex          sync.Mutex
+	//Last snapshot of each watched directory
+	dirs map[string]map[string]fileSnapshot
+}
+
+// Size and modification time of a file or directory
+type fileSnapshot struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	w := &dirWatcher{changes: make(chan fileChange, 100), ignoreRequests: make(chan chan struct{}),
+		dirs: map[string]map[string]fileSnapshot{}}
+	go w.poll()
+
+	return w, nil
+}
+
+// Starts watching a directory. Adding a directory which is already watched has no effect.
+func (w *dirWatcher) add(dir string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.dirs[dir]; !ok {
+		w.dirs[dir] = snapshotDir(dir)
+	}
+
+	return nil
+}
+
+// Compares the watched directories with their last snapshots twice a second. A request to ignore the changes made
+// so far is answered by taking new snapshots without reporting the differences.
+func (w *dirWatcher) poll() {
+	for {
+		select {
+		case done := <-w.ignoreRequests:
+			w.mutex.Lock()
+			for dir := range w.dirs {
+				w.dirs[dir] = snapshotDir(dir)
+			}
+			w.mutex.Unlock()
+
+			drainChanges(w.changes)
+			close(done)
+
+			continue
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		var changes []fileChange
+
+		w.mutex.Lock()
+		for dir, snapshot := range w.dirs {
+			current := snapshotDir(dir)
+
+			for name, file := range current {
+				if previous, ok := snapshot[name]; !ok || previous != file {
+					changes = append(changes, fileChange{path: filepath.Join(dir, name), isDir: file.isDir})
+				}
+			}
+
+			for name, file := range snapshot {
+				if _, ok := current[name]; !ok {
+					changes = append(changes, fileChange{path: filepath.Join(dir, name), isDir: file.isDir})
+				}
+			}
+
+			w.dirs[dir] = current
+		}
+		w.mutex.Unlock()
+
+		for _, change := range changes {
+			w.changes <- change
+		}
+	}
+}
+
+// Records the sizes and modification times of the files and subdirectories of a directory
+func snapshotDir(dir string) map[string]fileSnapshot {
+	snapshot := map[string]fileSnapshot{}
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return snapshot
+	}
+
+	for _, f := range files {
+		if !isEditorFile(f.Name()) {
+			snapshot[f.Name()] = fileSnapshot{f.IsDir(), f.Size(), f.ModTime()}
+		}
+	}
+
+	return snapshot
+}
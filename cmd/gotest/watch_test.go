@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/claassen/gotest"
+	. "github.com/claassen/gotest/assert"
+)
+
+func TestWatch() {
+	Describe("When watching a package for changes", func() {
+		var saved TestContext
+		var dir, testdata string
+		var watcher *dirWatcher
+
+		BeforeEach(func() {
+			saved = context
+
+			var err error
+			dir, err = ioutil.TempDir("", "gotest-watch-")
+			AssertThat(err).IsNil()
+
+			testdata = filepath.Join(dir, "testdata")
+			AssertThat(os.Mkdir(testdata, os.ModePerm)).IsNil()
+
+			context.dataDirs = map[string]string{testdata: dir}
+			context.embeds = map[string]string{}
+
+			watcher, err = newDirWatcher()
+			AssertThat(err).IsNil()
+			AssertThat(watcher.add(dir)).IsNil()
+			AssertThat(watcher.add(testdata)).IsNil()
+		})
+
+		AfterEach(func() {
+			context = saved
+			os.RemoveAll(dir)
+		})
+
+		ItWithTimeout("ignores the files its tests write to their testdata directory", 10*time.Second, func() {
+			//Written by the tests while they run
+			AssertThat(ioutil.WriteFile(filepath.Join(testdata, "actual.txt"), []byte("output"), 0644)).IsNil()
+			watcher.ignoreChanges()
+
+			select {
+			case change := <-watcher.changes:
+				Fail("Unexpected change to " + change.path)
+			case <-time.After(time.Second):
+			}
+
+			//Written once the tests have finished
+			AssertThat(ioutil.WriteFile(filepath.Join(testdata, "input.txt"), []byte("input"), 0644)).IsNil()
+			AssertThat(watcher.waitForChanges()).IsEqualTo(map[string]bool{dir: true})
+		})
+	})
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Creates the private directory the generated test packages are written to and the tests are built in
+func createWorkDir() {
+	workDir, err := ioutil.TempDir("", "gotest-")
+
+	if err != nil {
+		panic(fmt.Sprintf("Error creating temp directory: %s", err))
+	}
+
+	context.workDir = workDir
+}
+
+// Returns the directory in the work directory a generated package with the given import path is written to. The
+// work directory is laid out as a GOPATH, and in module mode each generated package is a module of its own in a
+// workspace with the module under test, so the generated packages keep the import paths they would have in the
+// source tree and may import its internal packages.
+func generatedPackageDir(importPath string) string {
+	return filepath.Join(context.workDir, "src", filepath.FromSlash(importPath))
+}
+
+// Creates a generated source file and the directories above it
+func createGeneratedFile(path string) *os.File {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		panic(fmt.Sprintf("Error creating directory %s : %s", filepath.Dir(path), err))
+	}
+
+	file, err := os.Create(path)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error creating file %s : %s", path, err))
+	}
+
+	return file
+}
+
+// Copies a file of the original package to the given path in a generated package
+func copyGeneratedFile(path string, originalPath string) {
+	data, err := ioutil.ReadFile(originalPath)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error reading file %s : %s", originalPath, err))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		panic(fmt.Sprintf("Error creating directory %s : %s", filepath.Dir(path), err))
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		panic(fmt.Sprintf("Error writing file %s : %s", path, err))
+	}
+}
+
+// Makes a generated package a module of its own, written in the same Go version as the module under test
+func createGeneratedModule(dir string, importPath string) {
+	goMod := "module " + importPath + "\n"
+
+	if goVersion := readGoModDirective(context.moduleRootPath, "go"); goVersion != "" {
+		goMod += "\ngo " + goVersion + "\n"
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		panic(fmt.Sprintf("Error writing file %s : %s", filepath.Join(dir, "go.mod"), err))
+	}
+}
+
+// Links the entries of the original directories above the generated packages into the work directory in GOPATH
+// mode. The work directory comes first in GOPATH so that these directories would otherwise hide the packages in
+// them, and a vendored package may only be imported from below the directory containing its vendor directory, so
+// the generated packages and the packages next to them have to be found in the same tree.
+func linkSourceDirs(path string) {
+	rel, err := filepath.Rel(filepath.Join(context.workDir, "src"), path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	for _, root := range filepath.SplitList(build.Default.GOPATH) {
+		originalPath := filepath.Join(root, "src", rel)
+
+		if info, err := os.Stat(originalPath); err != nil || !info.IsDir() {
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(originalPath)
+
+		if err != nil {
+			panic(fmt.Sprintf("Error reading directory %s : %s", originalPath, err))
+		}
+
+		for _, entry := range entries {
+			if linkPath := filepath.Join(path, entry.Name()); !pathExists(linkPath) {
+				if err := os.Symlink(filepath.Join(originalPath, entry.Name()), linkPath); err != nil {
+					panic(fmt.Sprintf("Error linking %s : %s", filepath.Join(originalPath, entry.Name()), err))
+				}
+			}
+		}
+
+		break
+	}
+
+	entries, err := ioutil.ReadDir(path)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error reading directory %s : %s", path, err))
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			linkSourceDirs(filepath.Join(path, entry.Name()))
+		}
+	}
+}
+
+// Returns the go.work file of the workspace the module under test is part of, or an empty string if there is none
+func currentWorkspace() string {
+	cmd := exec.Command("go", "env", "GOWORK")
+	cmd.Dir = context.moduleRootPath
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+
+	if err != nil {
+		panic(fmt.Sprintf("Error finding workspace: %s", err))
+	}
+
+	if goWork := strings.TrimSpace(string(out)); goWork != "off" {
+		return goWork
+	}
+
+	return ""
+}
+
+// Returns a directory of a go.work file as an absolute path
+func workspacePath(goWorkDir string, path string) string {
+	path = strings.Trim(path, "\"`")
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(goWorkDir, path)
+	}
+
+	return filepath.Clean(path)
+}
+
+// Reads a go.work file, returning its directives with relative paths made absolute and the modules it uses
+// listed separately
+func readWorkspace(goWorkPath string) (directives []string, uses []string) {
+	data, err := ioutil.ReadFile(goWorkPath)
+
+	if err != nil {
+		panic(fmt.Sprintf("Error reading %s : %s", goWorkPath, err))
+	}
+
+	goWorkDir := filepath.Dir(goWorkPath)
+	block := ""
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 0:
+			continue
+		case block != "" && fields[0] == ")":
+			if block != "use" {
+				directives = append(directives, ")")
+			}
+
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			if block = fields[0]; block != "use" {
+				directives = append(directives, line)
+			}
+
+			continue
+		}
+
+		directive := block
+
+		if directive == "" {
+			directive, fields = fields[0], fields[1:]
+		}
+
+		switch directive {
+		case "use":
+			for _, field := range fields {
+				uses = append(uses, workspacePath(goWorkDir, field))
+			}
+
+			if block != "" {
+				continue
+			}
+
+			line = ""
+		case "replace":
+			//Only replacements by directories are relative to the go.work file
+			if len(fields) > 0 && strings.HasPrefix(fields[len(fields)-1], ".") {
+				fields[len(fields)-1] = workspacePath(goWorkDir, fields[len(fields)-1])
+			}
+
+			line = strings.Join(fields, " ")
+
+			if block == "" {
+				line = "replace " + line
+			}
+		}
+
+		if line != "" {
+			directives = append(directives, line)
+		}
+	}
+
+	return directives, uses
+}
+
+// Returns the Go version of a workspace for a module written in the given version, which must be at least Go 1.18
+// as that is the first version to support workspaces
+func workspaceGoVersion(goVersion string) string {
+	parts := strings.SplitN(goVersion, ".", 3)
+
+	if len(parts) < 2 || parts[0] != "1" {
+		return "1.18"
+	}
+
+	minor := parts[1]
+
+	//Pre-release versions such as 1.21rc1
+	if index := strings.IndexFunc(minor, func(r rune) bool { return r < '0' || r > '9' }); index >= 0 {
+		minor = minor[:index]
+	}
+
+	if n, err := strconv.Atoi(minor); err != nil || n < 18 {
+		return "1.18"
+	}
+
+	return goVersion
+}
+
+// Writes the go.work file which builds the generated modules together with the module under test, along with the
+// other modules of the workspace it is part of
+func writeWorkspace() {
+	var directives, uses []string
+
+	if goWork := currentWorkspace(); goWork != "" {
+		directives, uses = readWorkspace(goWork)
+	} else {
+		directives = []string{"go " + workspaceGoVersion(readGoModDirective(context.moduleRootPath, "go"))}
+	}
+
+	uses = append(uses, context.moduleRootPath, context.testMainPackageDir)
+
+	for _, p := range context.testPackages {
+		uses = append(uses, p.testPackagePath)
+	}
+
+	goWork := strings.Join(directives, "\n") + "\n\nuse (\n"
+	used := map[string]bool{}
+
+	for _, use := range uses {
+		if !used[use] {
+			goWork += "\t" + strconv.Quote(use) + "\n"
+			used[use] = true
+		}
+	}
+
+	goWork += ")\n"
+
+	if err := ioutil.WriteFile(filepath.Join(context.workDir, "go.work"), []byte(goWork), 0644); err != nil {
+		panic(fmt.Sprintf("Error writing workspace: %s", err))
+	}
+}
+
+// Returns GOFLAGS without any -mod flag, which workspaces do not allow unless it is readonly
+func workspaceGoFlags() string {
+	var flags []string
+
+	for _, flag := range strings.Fields(os.Getenv("GOFLAGS")) {
+		if !strings.HasPrefix(flag, "-mod=") && !strings.HasPrefix(flag, "--mod=") {
+			flags = append(flags, flag)
+		}
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// Returns the environment the go command builds the tests in, which finds the generated packages in the work
+// directory
+func buildEnv() []string {
+	if isModuleMode() {
+		return append(os.Environ(), "GOWORK="+filepath.Join(context.workDir, "go.work"), "GOFLAGS="+workspaceGoFlags())
+	}
+
+	return append(os.Environ(), "GOPATH="+context.workDir+string(filepath.ListSeparator)+build.Default.GOPATH)
+}
+
+// Processes started by gotest. They are killed when gotest is interrupted so that they do not keep running on
+// their own.
+var children = struct {
+	sync.Mutex
+	processes map[*os.Process]bool
+	killed    bool
+}{processes: map[*os.Process]bool{}}
+
+func startChild(cmd *exec.Cmd) error {
+	children.Lock()
+	defer children.Unlock()
+
+	if children.killed {
+		return errors.New("interrupted")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	children.processes[cmd.Process] = true
+
+	return nil
+}
+
+func waitChild(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	children.Lock()
+	delete(children.processes, cmd.Process)
+	children.Unlock()
+
+	return err
+}
+
+func runChild(cmd *exec.Cmd) error {
+	if err := startChild(cmd); err != nil {
+		return err
+	}
+
+	return waitChild(cmd)
+}
+
+func killChildren() {
+	children.Lock()
+	defer children.Unlock()
+
+	children.killed = true
+
+	for process := range children.processes {
+		process.Kill()
+	}
+}
+
+// Stops the tests and cleans up when gotest is interrupted or terminated rather than leaving generated files
+// and processes behind
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		killChildren()
+		cleanup()
+		os.Exit(128 + int(sig.(syscall.Signal)))
+	}()
+}
+
+// Removes the generated packages which older versions of gotest wrote to the source tree and left behind when they
+// were killed before they could clean up
+func removeGeneratedDirs(path string, recursive bool) {
+	files, err := ioutil.ReadDir(path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		dirPath := filepath.Join(path, f.Name())
+
+		switch {
+		case f.Name() == "__testmain" || f.Name() == filepath.Base(path)+"__test" || f.Name() == filepath.Base(path)+"__xtest":
+			if err := os.RemoveAll(dirPath); err != nil {
+				panic(fmt.Sprintf("Error removing %s : %s", dirPath, err))
+			}
+
+			fmt.Println("Removed", dirPath)
+		case recursive && f.Name() != "vendor" && f.Name() != ".git" && f.Name() != "testdata":
+			removeGeneratedDirs(dirPath, recursive)
+		}
+	}
+}
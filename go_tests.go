@@ -0,0 +1,115 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	gotesting "testing"
+)
+
+// Environment variable set when the test binary is started to run a single test written for the standard
+// testing package
+const goTestEnv = "GOTEST_GO_TEST"
+
+// Tests written for the standard testing package by the full description of the It block they are run from
+var goTests = map[string]gotesting.InternalTest{}
+
+// GoTests adds a Describe block with an It block for each of the given test functions written for the standard
+// testing package, so that they are run and reported along with the other tests. The gotest command calls it for
+// the TestXxx(t *testing.T) functions it finds in test files.
+func GoTests(description string, tests []gotesting.InternalTest) {
+	Describe(description, func() {
+		describe := t.currentBlock
+
+		for _, test := range tests {
+			test := test
+			key := describe.fullDescription() + " " + test.Name
+			goTests[key] = test
+
+			It(test.Name, func() {
+				runGoTest(key, test.Name)
+			})
+
+			//The tests are declared by the test functions rather than by this function
+			entry := reflect.ValueOf(test.F).Pointer()
+			it := describe.children[len(describe.children)-1]
+			it.file, it.line = runtime.FuncForPC(entry).FileLine(entry)
+		}
+
+		if len(describe.children) > 0 {
+			describe.file, describe.line = describe.children[0].file, describe.children[0].line
+		}
+	})
+}
+
+// Runs a test written for the standard testing package in a new copy of the test binary, as the standard testing
+// package exits once it has run its tests. Its output is used as the failure message when it fails and as the
+// reason when it is skipped.
+func runGoTest(key string, name string) {
+	executable, err := os.Executable()
+
+	if err != nil {
+		panic(fmt.Sprintf("Error running %s: %s", name, err))
+	}
+
+	cmd := exec.Command(executable, "-test.v=true")
+	cmd.Env = append(os.Environ(), goTestEnv+"="+key)
+
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		panic(indentFailure(goTestOutput(output)))
+	}
+
+	if strings.Contains(string(output), "--- SKIP: "+name+" ") {
+		Skip(goTestOutput(output))
+	}
+}
+
+// Removes the lines the standard testing package writes around the output of a test
+func goTestOutput(output []byte) string {
+	var lines []string
+
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if strings.HasPrefix(line, "=== ") || strings.HasPrefix(line, "--- ") || line == "PASS" || line == "FAIL" {
+			continue
+		}
+
+		lines = append(lines, strings.TrimPrefix(line, "    "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Indents the output of a failed test the way assertion failures are, with the lines continuing a logged message
+// indented further
+func indentFailure(output string) string {
+	buf := new(bytes.Buffer)
+
+	for _, line := range strings.Split(strings.TrimRight(output, " \n"), "\n") {
+		if strings.HasPrefix(line, "    ") {
+			buf.WriteString("\t\t" + strings.TrimPrefix(line, "    ") + "\n")
+		} else {
+			buf.WriteString("\t" + line + "\n")
+		}
+	}
+
+	return buf.String()
+}
+
+// Runs the test a copy of the test binary was started for with the standard testing package, which then exits
+func runGoTestProcess(key string) {
+	test, ok := goTests[key]
+
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Unknown test:", key)
+		os.Exit(1)
+	}
+
+	matchAll := func(pattern, name string) (bool, error) { return true, nil }
+	gotesting.Main(matchAll, []gotesting.InternalTest{test}, nil, nil)
+}
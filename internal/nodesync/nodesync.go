@@ -0,0 +1,9 @@
+// Package nodesync names the files used to synchronize suite hooks between the nodes tests are split across. The test
+// binary waits on them and the gotest command creates them on behalf of nodes which exit early.
+package nodesync
+
+const (
+	BeforeSuiteDataFile   = "before-suite"
+	BeforeSuiteFailedFile = "before-suite-failed"
+	NodeDoneFilePrefix    = "node-done-"
+)
@@ -0,0 +1,222 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A single event in the format written by go test -json
+type jsonEvent struct {
+	Time    time.Time
+	Action  string
+	Package string   `json:",omitempty"`
+	Test    string   `json:",omitempty"`
+	Elapsed *float64 `json:",omitempty"`
+	Output  string   `json:",omitempty"`
+}
+
+type jsonReporter struct {
+	encoder *json.Encoder
+	//Names of the currently entered Describe blocks, whether any test inside each of them failed and the package
+	//each was reported in. A Describe block is reported when its first test starts, as the test gives its package.
+	describes []string
+	failed    []bool
+	reported  []bool
+	packages  []string
+	//Packages in the order they were started, along with the time taken by their tests and whether any failed
+	started         []string
+	packageDuration map[string]time.Duration
+	packageFailed   map[string]bool
+	//Package of the last test, which failures of AfterSuite blocks are reported in as they belong to no package
+	lastPackage string
+	//Seed used to randomize the order of the tests, written as output of every package
+	seed int64
+}
+
+// NewJSONReporter creates a reporter which writes a stream of events compatible with the output of go test -json.
+// Describe and It blocks are reported as nested tests named the way go test names subtests, and every event has
+// the import path of the package the test belongs to.
+func NewJSONReporter(out io.Writer) Reporter {
+	return &jsonReporter{encoder: json.NewEncoder(out), packageDuration: map[string]time.Duration{}, packageFailed: map[string]bool{}}
+}
+
+// Returns the go test style name of a test nested inside the given Describe blocks
+func jsonTestName(containers []string, description string) string {
+	names := append(append([]string{}, containers...), description)
+
+	for i, name := range names {
+		names[i] = strings.Replace(strings.TrimSpace(name), " ", "_", -1)
+	}
+
+	return strings.Join(names, "/")
+}
+
+func (r *jsonReporter) emit(action, pkg, test, output string) {
+	r.encoder.Encode(jsonEvent{Time: time.Now(), Action: action, Package: pkg, Test: test, Output: output})
+}
+
+// Emits the final pass, fail or skip event of a test along with the time it took
+func (r *jsonReporter) emitResult(action, pkg, test string, duration time.Duration) {
+	elapsed := duration.Seconds()
+	r.encoder.Encode(jsonEvent{Time: time.Now(), Action: action, Package: pkg, Test: test, Elapsed: &elapsed})
+}
+
+// Returns the package of a test, emitting the start event of the package when it is the first test in it
+func (r *jsonReporter) specPackage(spec SpecReport) string {
+	pkg := spec.Package
+
+	if pkg == "" {
+		pkg = r.lastPackage
+	}
+
+	if _, ok := r.packageDuration[pkg]; !ok {
+		r.started = append(r.started, pkg)
+		r.packageDuration[pkg] = 0
+		r.startPackage(pkg)
+	}
+
+	r.lastPackage = pkg
+
+	return pkg
+}
+
+func (r *jsonReporter) startPackage(pkg string) {
+	r.emit("start", pkg, "", "")
+
+	if r.seed != 0 {
+		r.emit("output", pkg, "", fmt.Sprintf("Randomized with seed %d\n", r.seed))
+	}
+}
+
+func (r *jsonReporter) markFailed(pkg string) {
+	for i := range r.failed {
+		r.failed[i] = true
+	}
+
+	r.packageFailed[pkg] = true
+}
+
+func (r *jsonReporter) SuiteStarted(suite SuiteInfo) {
+	r.seed = suite.RandomSeed
+}
+
+func (r *jsonReporter) DescribeEntered(description string) {
+	r.describes = append(r.describes, description)
+	r.failed = append(r.failed, false)
+	r.reported = append(r.reported, false)
+	r.packages = append(r.packages, "")
+}
+
+func (r *jsonReporter) DescribeExited(description string, duration time.Duration) {
+	last := len(r.describes) - 1
+	test := jsonTestName(r.describes[:last], r.describes[last])
+	failed, reported, pkg := r.failed[last], r.reported[last], r.packages[last]
+
+	r.describes = r.describes[:last]
+	r.failed = r.failed[:last]
+	r.reported = r.reported[:last]
+	r.packages = r.packages[:last]
+
+	if !reported {
+		return
+	}
+
+	if failed {
+		r.emitResult("fail", pkg, test, duration)
+	} else {
+		r.emitResult("pass", pkg, test, duration)
+	}
+}
+
+func (r *jsonReporter) SpecStarted(spec SpecReport) {
+	pkg := r.specPackage(spec)
+
+	for i := range r.describes {
+		if r.reported[i] {
+			continue
+		}
+
+		test := jsonTestName(r.describes[:i], r.describes[i])
+		r.reported[i] = true
+		r.packages[i] = pkg
+
+		r.emit("run", pkg, test, "")
+		r.emit("output", pkg, test, "=== RUN   "+test+"\n")
+	}
+
+	test := jsonTestName(spec.Containers, spec.Description)
+
+	r.emit("run", pkg, test, "")
+	r.emit("output", pkg, test, "=== RUN   "+test+"\n")
+}
+
+func (r *jsonReporter) SpecPassed(spec SpecReport) {
+	pkg := r.specPackage(spec)
+	test := jsonTestName(spec.Containers, spec.Description)
+
+	r.emit("output", pkg, test, "--- PASS: "+test+"\n")
+	r.emitResult("pass", pkg, test, spec.Duration)
+	r.packageDuration[pkg] += spec.Duration
+}
+
+func (r *jsonReporter) SpecFailed(spec SpecReport) {
+	pkg := r.specPackage(spec)
+	test := jsonTestName(spec.Containers, spec.Description)
+
+	r.emit("output", pkg, test, "--- FAIL: "+test+"\n")
+
+	for _, line := range strings.Split(strings.TrimRight(spec.Failure, "\n"), "\n") {
+		r.emit("output", pkg, test, "    "+line+"\n")
+	}
+
+	for _, location := range specLocations(spec) {
+		r.emit("output", pkg, test, "    "+location+"\n")
+	}
+
+	r.emitResult("fail", pkg, test, spec.Duration)
+	r.packageDuration[pkg] += spec.Duration
+	r.markFailed(pkg)
+}
+
+func (r *jsonReporter) SpecSkipped(spec SpecReport) {
+	pkg := r.specPackage(spec)
+	test := jsonTestName(spec.Containers, spec.Description)
+	reason := spec.SkipReason
+
+	if spec.Pending {
+		reason = "pending"
+	}
+
+	r.emit("output", pkg, test, "--- SKIP: "+test+"\n")
+
+	if reason != "" {
+		r.emit("output", pkg, test, "    "+reason+"\n")
+	}
+
+	r.emitResult("skip", pkg, test, spec.Duration)
+	r.packageDuration[pkg] += spec.Duration
+}
+
+// Ends each package with a pass or fail event, as go test does. A run without any tests ends the same way.
+func (r *jsonReporter) SuiteEnded(summary Summary) {
+	if len(r.started) == 0 {
+		r.startPackage("")
+		r.started = append(r.started, "")
+	}
+
+	//The time taken by the whole run includes the suite hooks of a single package
+	if len(r.started) == 1 {
+		r.packageDuration[r.started[0]] = summary.Duration
+	}
+
+	for _, pkg := range r.started {
+		if r.packageFailed[pkg] {
+			r.emitResult("fail", pkg, "", r.packageDuration[pkg])
+		} else {
+			r.emitResult("pass", pkg, "", r.packageDuration[pkg])
+		}
+	}
+}
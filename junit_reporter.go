@@ -0,0 +1,130 @@
+package testing
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Cases      []junitTestCase  `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitReporter struct {
+	out   io.Writer
+	suite junitTestSuite
+}
+
+// NewJUnitReporter creates a reporter which writes a JUnit XML report once all tests have been run
+func NewJUnitReporter(out io.Writer) Reporter {
+	return &junitReporter{out: out, suite: junitTestSuite{Name: "gotest"}}
+}
+
+func newJUnitTestCase(spec SpecReport) junitTestCase {
+	testCase := junitTestCase{Name: spec.Description, ClassName: strings.Join(spec.Containers, " "), Time: junitTime(spec.Duration)}
+
+	if spec.File != "" {
+		testCase.File, testCase.Line = displayPath(spec.File), spec.Line
+	}
+
+	return testCase
+}
+
+// Formats a duration as seconds the way JUnit reports expect
+func junitTime(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+}
+
+func (r *junitReporter) SuiteStarted(suite SuiteInfo) {
+	//The seed is needed to run the tests in the same order again
+	if suite.RandomSeed != 0 {
+		r.suite.Properties = &junitProperties{[]junitProperty{{Name: "seed", Value: strconv.FormatInt(suite.RandomSeed, 10)}}}
+	}
+}
+
+func (r *junitReporter) DescribeEntered(description string) {}
+
+func (r *junitReporter) DescribeExited(description string, duration time.Duration) {}
+
+func (r *junitReporter) SpecStarted(spec SpecReport) {}
+
+func (r *junitReporter) SpecPassed(spec SpecReport) {
+	r.suite.Cases = append(r.suite.Cases, newJUnitTestCase(spec))
+}
+
+func (r *junitReporter) SpecFailed(spec SpecReport) {
+	testCase := newJUnitTestCase(spec)
+	message := strings.TrimSpace(strings.SplitN(strings.TrimSpace(spec.Failure), "\n", 2)[0])
+	contents := strings.Join(append([]string{strings.TrimRight(spec.Failure, "\n")}, specLocations(spec)...), "\n")
+	testCase.Failure = &junitFailure{Message: message, Contents: contents}
+
+	r.suite.Cases = append(r.suite.Cases, testCase)
+	r.suite.Failures++
+}
+
+func (r *junitReporter) SpecSkipped(spec SpecReport) {
+	testCase := newJUnitTestCase(spec)
+	testCase.Skipped = &junitSkipped{Message: spec.SkipReason}
+
+	if spec.Pending {
+		testCase.Skipped.Message = "pending"
+	}
+
+	r.suite.Cases = append(r.suite.Cases, testCase)
+	r.suite.Skipped++
+}
+
+func (r *junitReporter) SuiteEnded(summary Summary) {
+	r.suite.Tests = len(r.suite.Cases)
+	r.suite.Time = junitTime(summary.Duration)
+
+	output, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{r.suite}}, "", "\t")
+
+	if err != nil {
+		panic(fmt.Sprintf("Error writing JUnit report: %s", err))
+	}
+
+	fmt.Fprintln(r.out, xml.Header+string(output))
+}
@@ -0,0 +1,39 @@
+package testing
+
+import (
+	"bytes"
+	gotesting "testing"
+
+	. "github.com/claassen/gotest/assert"
+)
+
+func junitReportTests() {
+	Describe("When writing a JUnit report", func() {
+
+		report := func(suite SuiteInfo) string {
+			var out bytes.Buffer
+			reporter := NewJUnitReporter(&out)
+
+			reporter.SuiteStarted(suite)
+			reporter.SpecPassed(SpecReport{FullDescription: "a", Description: "a"})
+			reporter.SuiteEnded(Summary{Passed: 1})
+
+			return out.String()
+		}
+
+		It("records the seed of randomized runs as a property", func() {
+			Expect(report(SuiteInfo{RandomSeed: 42})).To(ContainSubstring("<properties>\n\t\t\t<property name=\"seed\" value=\"42\"></property>\n\t\t</properties>"))
+		})
+
+		It("leaves out the properties of runs in declaration order", func() {
+			Expect(report(SuiteInfo{})).NotTo(ContainSubstring("properties"))
+		})
+	})
+}
+
+// The tests of this package are run with RunSpecs as the gotest command runs them against a copy of the package,
+// which has a runner of its own
+func TestJUnitReporter(gt *gotesting.T) {
+	junitReportTests()
+	RunSpecs(gt)
+}
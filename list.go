@@ -0,0 +1,75 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A test in the list written with -list=json
+type listedSpec struct {
+	FullDescription string
+	Containers      []string
+	Description     string
+	File            string
+	Line            int
+	Pending         bool `json:",omitempty"`
+}
+
+// Writes the Describe and It blocks containing tests which would be run as an indented tree
+func listBlocks(out io.Writer, blocks []*block, state runState, depth int) {
+	for _, b := range blocks {
+		blockState := b.enter(state)
+
+		if b.blockType == describe && b.countTests(blockState, true) == 0 {
+			continue
+		}
+
+		if b.blockType == it && !t.shouldRun(blockState.description, blockState.focused) {
+			continue
+		}
+
+		description := b.description
+
+		if b.blockType == it && blockState.pending {
+			description += " (pending)"
+		}
+
+		fmt.Fprintf(out, "%s%s  %s:%d\n", strings.Repeat("  ", depth), description, displayPath(b.file), b.line)
+
+		listBlocks(out, b.children, blockState, depth+1)
+	}
+}
+
+// Writes the tests which would be run, given the focused blocks and the --focus and --skip filters, without running
+// any tests or hooks. The format is either tree or json.
+func listSpecs(out io.Writer, format string) {
+	if format == "json" {
+		specs := []listedSpec{}
+
+		walkTests(t.topLevelBlocks, runState{}, func(b *block, state runState) {
+			if t.shouldRun(state.description, state.focused) {
+				specs = append(specs, listedSpec{
+					FullDescription: state.description,
+					Containers:      append([]string{}, state.containers...),
+					Description:     b.description,
+					File:            b.file,
+					Line:            b.line,
+					Pending:         state.pending,
+				})
+			}
+		})
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(specs)
+
+		return
+	}
+
+	listBlocks(out, t.topLevelBlocks, runState{}, 0)
+
+	fmt.Fprintln(out, "-----------")
+	fmt.Fprintf(out, "%d tests\n", countTests(t.topLevelBlocks, runState{}, true))
+}
@@ -0,0 +1,350 @@
+package testing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/claassen/gotest/internal/nodesync"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A single result recorded by a node. The last entry written by a node holds its summary.
+type nodeEvent struct {
+	//Position of the test among all tests and, for failures of AfterAll blocks, the order after that test
+	Index    int
+	SubIndex int
+	Outcome  string
+	Spec     SpecReport
+	Summary  *Summary `json:",omitempty"`
+}
+
+// Records the results of a node to a file so that they can be merged with the results of the other nodes
+type nodeReporter struct {
+	encoder   *json.Encoder
+	lastIndex int
+	subIndex  int
+}
+
+func newNodeReporter(out io.Writer) Reporter {
+	return &nodeReporter{encoder: json.NewEncoder(out), lastIndex: -1}
+}
+
+func (r *nodeReporter) record(outcome string, spec SpecReport) {
+	//Failures of AfterAll and AfterSuite blocks are ordered after the last test run before them
+	if t.currentIndex == r.lastIndex {
+		r.subIndex++
+	} else {
+		r.lastIndex = t.currentIndex
+		r.subIndex = 0
+	}
+
+	r.encoder.Encode(nodeEvent{Index: t.currentIndex, SubIndex: r.subIndex, Outcome: outcome, Spec: spec})
+}
+
+func (r *nodeReporter) SuiteStarted(suite SuiteInfo) {}
+
+func (r *nodeReporter) DescribeEntered(description string) {}
+
+func (r *nodeReporter) DescribeExited(description string, duration time.Duration) {}
+
+func (r *nodeReporter) SpecStarted(spec SpecReport) {}
+
+func (r *nodeReporter) SpecPassed(spec SpecReport) {
+	r.record("passed", spec)
+}
+
+func (r *nodeReporter) SpecFailed(spec SpecReport) {
+	r.record("failed", spec)
+}
+
+func (r *nodeReporter) SpecSkipped(spec SpecReport) {
+	r.record("skipped", spec)
+}
+
+func (r *nodeReporter) SuiteEnded(summary Summary) {
+	r.encoder.Encode(nodeEvent{Index: math.MaxInt32, Summary: &summary})
+}
+
+// Numbers the tests in declaration order and, when running as one of several nodes, deals the tests to run out
+// to the nodes in turn. Tests which are filtered out keep their place in the order as they are reported as skipped.
+func assignNodes(state runState) {
+	index, selected := 0, 0
+
+	walkTests(t.topLevelBlocks, state, func(b *block, state runState) {
+		b.index = index
+		index++
+
+		if t.shouldRun(state.description, state.focused) {
+			b.node = selected%t.nodes + 1
+			selected++
+		}
+	})
+}
+
+// SynchronizedBeforeSuite adds a BeforeSuite block for tests split across several nodes with the -p option.
+// The primary function is called once, on the first node, before any tests are run on any node. The data it
+// returns is passed to the allNodes function which is called on every node. When tests are not split across
+// nodes both functions are simply called one after the other.
+func SynchronizedBeforeSuite(primary func() []byte, allNodes func(data []byte)) {
+	BeforeSuite(func() {
+		if t.nodes <= 1 || t.syncDir == "" {
+			allNodes(primary())
+			return
+		}
+
+		if t.node == 1 {
+			allNodes(runPrimaryBeforeSuite(primary))
+		} else {
+			allNodes(waitForPrimaryBeforeSuite())
+		}
+	})
+}
+
+// SynchronizedAfterSuite adds an AfterSuite block for tests split across several nodes with the -p option.
+// The allNodes function is called on every node once it has run its tests. The primary function is called
+// once, on the first node, after all other nodes have finished.
+func SynchronizedAfterSuite(allNodes func(), primary func()) {
+	AfterSuite(func() {
+		if t.nodes <= 1 || t.syncDir == "" {
+			allNodes()
+			primary()
+			return
+		}
+
+		if t.node != 1 {
+			defer writeSyncFile(nodesync.NodeDoneFilePrefix+strconv.Itoa(t.node), nil)
+			allNodes()
+			return
+		}
+
+		err := capture(allNodes)
+
+		for node := 2; node <= t.nodes; node++ {
+			waitForSyncFile(nodesync.NodeDoneFilePrefix + strconv.Itoa(node))
+		}
+
+		primary()
+
+		if err != nil {
+			panic(err)
+		}
+	})
+}
+
+func runPrimaryBeforeSuite(primary func() []byte) (data []byte) {
+	defer func() {
+		if err := recover(); err != nil {
+			writeSyncFile(nodesync.BeforeSuiteFailedFile, []byte(fmt.Sprint(err)))
+			panic(err)
+		}
+	}()
+
+	data = primary()
+	writeSyncFile(nodesync.BeforeSuiteDataFile, data)
+
+	return data
+}
+
+func waitForPrimaryBeforeSuite() []byte {
+	for {
+		if message, err := ioutil.ReadFile(filepath.Join(t.syncDir, nodesync.BeforeSuiteFailedFile)); err == nil {
+			panic(fmt.Sprintf("SynchronizedBeforeSuite failed on the primary node:\n%s", message))
+		}
+
+		if data, err := ioutil.ReadFile(filepath.Join(t.syncDir, nodesync.BeforeSuiteDataFile)); err == nil {
+			return data
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Writes a file to the sync directory. The file is renamed into place so that other nodes never see it half written.
+func writeSyncFile(name string, data []byte) {
+	path := filepath.Join(t.syncDir, name)
+
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		panic(fmt.Sprintf("Error writing %s : %s", path, err))
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		panic(fmt.Sprintf("Error writing %s : %s", path, err))
+	}
+}
+
+func waitForSyncFile(name string) {
+	for {
+		if _, err := os.Stat(filepath.Join(t.syncDir, name)); err == nil {
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Reads the results recorded by a node. The summary is nil if the node exited before finishing.
+func readNodeResults(path string) (events []nodeEvent, summary *Summary) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var event nodeEvent
+
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			break
+		}
+
+		if event.Summary != nil {
+			summary = event.Summary
+		} else {
+			events = append(events, event)
+		}
+	}
+
+	return events, summary
+}
+
+func addOutcome(summary *Summary, event nodeEvent) {
+	switch {
+	case event.Outcome == "passed":
+		summary.Passed++
+	case event.Outcome == "failed":
+		summary.Failed++
+	case event.Spec.Pending:
+		summary.Pending++
+	default:
+		summary.Skipped++
+	}
+}
+
+// Reads the results recorded by each node and returns them in declaration order, along with the counts of all
+// nodes. Nodes which exited before finishing are reported as failed after all tests.
+func mergeNodeEvents(paths []string) (events []nodeEvent, summary Summary) {
+	for i, path := range paths {
+		nodeEvents, nodeSummary := readNodeResults(path)
+		events = append(events, nodeEvents...)
+
+		if nodeSummary != nil {
+			summary.Passed += nodeSummary.Passed
+			summary.Failed += nodeSummary.Failed
+			summary.Skipped += nodeSummary.Skipped
+			summary.Pending += nodeSummary.Pending
+
+			if nodeSummary.Duration > summary.Duration {
+				summary.Duration = nodeSummary.Duration
+			}
+
+			continue
+		}
+
+		//The node crashed or was killed so its results are incomplete
+		for _, event := range nodeEvents {
+			addOutcome(&summary, event)
+		}
+
+		description := fmt.Sprintf("Node %d", i+1)
+		events = append(events, nodeEvent{
+			Index:    math.MaxInt32,
+			SubIndex: i,
+			Outcome:  "failed",
+			Spec:     SpecReport{FullDescription: description, Description: description, Failure: "Node exited before all of its tests were run"},
+		})
+		summary.Failed++
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Index != events[j].Index {
+			return events[i].Index < events[j].Index
+		}
+
+		return events[i].SubIndex < events[j].SubIndex
+	})
+
+	return events, summary
+}
+
+// Reports the merged results of all nodes in declaration order and exits
+func mergeNodeResults(output io.Closer) {
+	events, summary := mergeNodeEvents(t.mergePaths)
+
+	t.reporter.SuiteStarted(suiteInfo())
+
+	//Describe blocks are reconstructed from the containers of consecutive tests. Their durations are the sum of
+	//the durations of their tests as they may have been run on several nodes.
+	var describes []string
+	var durations []time.Duration
+
+	exitDescribes := func(depth int) {
+		for len(describes) > depth {
+			last := len(describes) - 1
+			t.reporter.DescribeExited(describes[last], durations[last])
+			describes = describes[:last]
+			durations = durations[:last]
+		}
+	}
+
+	for _, event := range events {
+		containers := event.Spec.Containers
+		common := 0
+
+		for common < len(describes) && common < len(containers) && describes[common] == containers[common] {
+			common++
+		}
+
+		exitDescribes(common)
+
+		for _, description := range containers[common:] {
+			t.reporter.DescribeEntered(description)
+			describes = append(describes, description)
+			durations = append(durations, 0)
+		}
+
+		for i := range durations {
+			durations[i] += event.Spec.Duration
+		}
+
+		t.reporter.SpecStarted(event.Spec)
+
+		switch event.Outcome {
+		case "passed":
+			t.reporter.SpecPassed(event.Spec)
+		case "failed":
+			t.reporter.SpecFailed(event.Spec)
+		default:
+			t.reporter.SpecSkipped(event.Spec)
+		}
+
+		if !event.Spec.Pending {
+			t.ranSpecs = append(t.ranSpecs, event.Spec)
+		}
+	}
+
+	exitDescribes(0)
+
+	summary.SlowSpecs = slowSpecs()
+	t.reporter.SuiteEnded(summary)
+
+	if output != nil {
+		output.Close()
+	}
+
+	if summary.Failed == 0 {
+		os.Exit(0)
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,141 @@
+package testing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	gotesting "testing"
+
+	. "github.com/claassen/gotest/assert"
+)
+
+func spec(description string) SpecReport {
+	return SpecReport{FullDescription: description, Description: description}
+}
+
+func parallelTests() {
+	Describe("When merging the results of several nodes", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "gotest-merge-")
+			AssertThat(err).IsNil()
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		//Writes the results of a node to a file as the node reporter does and returns its path
+		writeNodeResults := func(name string, events ...nodeEvent) string {
+			path := filepath.Join(dir, name)
+			file, err := os.Create(path)
+			AssertThat(err).IsNil()
+			defer file.Close()
+
+			encoder := json.NewEncoder(file)
+
+			for _, event := range events {
+				encoder.Encode(event)
+			}
+
+			return path
+		}
+
+		descriptions := func(events []nodeEvent) []string {
+			var order []string
+
+			for _, event := range events {
+				order = append(order, event.Spec.FullDescription)
+			}
+
+			return order
+		}
+
+		It("reports the tests in declaration order", func() {
+			events, _ := mergeNodeEvents([]string{
+				writeNodeResults("node-1.json",
+					nodeEvent{Index: 0, Outcome: "passed", Spec: spec("a")},
+					nodeEvent{Index: 2, Outcome: "passed", Spec: spec("c")},
+					nodeEvent{Index: 2, SubIndex: 1, Outcome: "failed", Spec: spec("AfterAll c")},
+					nodeEvent{Summary: &Summary{Passed: 2, Failed: 1}}),
+				writeNodeResults("node-2.json",
+					nodeEvent{Index: 3, Outcome: "failed", Spec: spec("d")},
+					nodeEvent{Index: 1, Outcome: "skipped", Spec: spec("b")},
+					nodeEvent{Summary: &Summary{Failed: 1, Skipped: 1}}),
+			})
+
+			AssertThat(descriptions(events)).IsEqualTo([]string{"a", "b", "c", "AfterAll c", "d"})
+		})
+
+		It("counts the tests of every node, including nodes which exited early", func() {
+			//The second node exited after its first test, so it has no summary
+			events, summary := mergeNodeEvents([]string{
+				writeNodeResults("node-1.json",
+					nodeEvent{Index: 0, Outcome: "passed", Spec: spec("a")},
+					nodeEvent{Index: 2, Outcome: "failed", Spec: spec("c")},
+					nodeEvent{Index: 4, Outcome: "skipped", Spec: SpecReport{FullDescription: "e", Pending: true}},
+					nodeEvent{Summary: &Summary{Passed: 1, Failed: 1, Pending: 1}}),
+				writeNodeResults("node-2.json",
+					nodeEvent{Index: 1, Outcome: "passed", Spec: spec("b")}),
+			})
+
+			AssertThat(summary).IsEqualTo(Summary{Passed: 2, Failed: 2, Pending: 1})
+			AssertThat(descriptions(events)).IsEqualTo([]string{"a", "b", "c", "e", "Node 2"})
+			AssertThat(events[len(events)-1].Outcome).IsEqualTo("failed")
+		})
+	})
+
+	Describe("When splitting tests between nodes", func() {
+
+		It("numbers every test and deals the tests to run out to the nodes in turn", func() {
+			saved := t
+			defer func() { t = saved }()
+
+			t = testContext{node: 1, nodes: 3, specRunner: runSpecDirectly, focusRegex: regexp.MustCompile("run")}
+
+			Describe("outer", func() {
+				It("run 1", func() {})
+				It("filtered", func() {})
+
+				Describe("inner", func() {
+					It("run 2", func() {})
+					XIt("run 3", func() {})
+				})
+
+				It("run 4", func() {})
+			})
+
+			assignNodes(runState{})
+
+			var indexes, nodes []int
+
+			walkTests(t.topLevelBlocks, runState{}, func(b *block, state runState) {
+				indexes = append(indexes, b.index)
+
+				if t.shouldRun(state.description, state.focused) {
+					nodes = append(nodes, b.node)
+				}
+			})
+
+			var counts []int
+
+			for node := 1; node <= t.nodes; node++ {
+				t.node = node
+				counts = append(counts, countTests(t.topLevelBlocks, runState{}, true))
+			}
+
+			AssertThat(indexes).IsEqualTo([]int{0, 1, 2, 3, 4})
+			AssertThat(nodes).IsEqualTo([]int{1, 2, 3, 1})
+			AssertThat(counts).IsEqualTo([]int{2, 1, 1})
+		})
+	})
+}
+
+func TestParallel(gt *gotesting.T) {
+	parallelTests()
+	RunSpecs(gt)
+}
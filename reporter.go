@@ -0,0 +1,302 @@
+package testing
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Reporter receives events as tests are run. Reporters can be added with AddReporter in addition to the built in
+// reporters selected with the --reporter option of the gotest command.
+type Reporter interface {
+	SuiteStarted(suite SuiteInfo)
+	DescribeEntered(description string)
+	DescribeExited(description string, duration time.Duration)
+	SpecStarted(spec SpecReport)
+	SpecPassed(spec SpecReport)
+	SpecFailed(spec SpecReport)
+	SpecSkipped(spec SpecReport)
+	SuiteEnded(summary Summary)
+}
+
+// SuiteInfo describes a test run as it starts
+type SuiteInfo struct {
+	//Number of tests that will be run or reported as pending
+	SpecCount int
+	//Seed used to randomize the order of the tests, zero when they are run in declaration order
+	RandomSeed int64
+}
+
+// SpecReport describes a single test
+type SpecReport struct {
+	//Descriptions of all enclosing Describe blocks and the It block joined with spaces
+	FullDescription string
+	//Descriptions of the enclosing Describe blocks, outermost first
+	Containers []string
+	//Description of the It block
+	Description string
+	//Import path of the package the test was declared in, empty unless it was run by the gotest command
+	Package string
+	//Failure message of a failed test
+	Failure string
+	//Reason passed to Skip for a skipped test, or "filtered" for a test left out by the focus and skip options
+	SkipReason string
+	//Set when a skipped test is pending rather than skipped at runtime
+	Pending bool
+	//Wall-clock time taken by the test including its BeforeEach and AfterEach blocks
+	Duration time.Duration
+	//Wall-clock time taken by the BeforeEach and AfterEach blocks of the test
+	HookDuration time.Duration
+	//File and line the It block was declared at
+	File string
+	Line int
+	//File and line of the test code which panicked when a test failed, if it could be found from the stack
+	FailureFile string
+	FailureLine int
+}
+
+// Summary holds the totals for a test run
+type Summary struct {
+	Passed  int
+	Failed  int
+	Skipped int
+	Pending int
+	//Wall-clock time taken by the whole test run
+	Duration time.Duration
+	//Tests which took longer than the --slow-threshold option, slowest first
+	SlowSpecs []SpecReport
+}
+
+// Skip reason of the tests left out by the focus and skip options
+const filteredSkipReason = "filtered"
+
+// Working directory the tests were started from. Tests are run in the directories of their packages, but file
+// paths are shown relative to this directory.
+var startDir, _ = os.Getwd()
+
+// Returns the path of a file relative to the directory the tests were started from if it is inside it, to keep the
+// output short
+func displayPath(file string) string {
+	if startDir == "" {
+		return file
+	}
+
+	if relPath, err := filepath.Rel(startDir, file); err == nil && !strings.HasPrefix(relPath, "..") {
+		return relPath
+	}
+
+	return file
+}
+
+// Returns lines saying where a failed test failed and where it was declared, as far as they are known
+func specLocations(spec SpecReport) []string {
+	var locations []string
+
+	if spec.FailureFile != "" {
+		locations = append(locations, fmt.Sprintf("Failed at: %s:%d", displayPath(spec.FailureFile), spec.FailureLine))
+	}
+
+	if spec.File != "" {
+		locations = append(locations, fmt.Sprintf("Declared at: %s:%d", displayPath(spec.File), spec.Line))
+	}
+
+	return locations
+}
+
+// AddReporter adds a reporter which will receive events for all tests run by RunTests
+func AddReporter(reporter Reporter) {
+	t.reporters = append(t.reporters, reporter)
+}
+
+// Creates one of the built in reporters by name
+func newReporter(name string, out io.Writer) (Reporter, error) {
+	switch name {
+	case "text":
+		return NewTextReporter(out), nil
+	case "junit":
+		return NewJUnitReporter(out), nil
+	case "tap":
+		return NewTAPReporter(out), nil
+	case "json":
+		return NewJSONReporter(out), nil
+	}
+
+	return nil, fmt.Errorf("unknown reporter %q, must be one of text, junit, tap or json", name)
+}
+
+// Passes events on to a list of reporters
+type multiReporter []Reporter
+
+func (reporters multiReporter) SuiteStarted(suite SuiteInfo) {
+	for _, r := range reporters {
+		r.SuiteStarted(suite)
+	}
+}
+
+func (reporters multiReporter) DescribeEntered(description string) {
+	for _, r := range reporters {
+		r.DescribeEntered(description)
+	}
+}
+
+func (reporters multiReporter) DescribeExited(description string, duration time.Duration) {
+	for _, r := range reporters {
+		r.DescribeExited(description, duration)
+	}
+}
+
+func (reporters multiReporter) SpecStarted(spec SpecReport) {
+	for _, r := range reporters {
+		r.SpecStarted(spec)
+	}
+}
+
+func (reporters multiReporter) SpecPassed(spec SpecReport) {
+	for _, r := range reporters {
+		r.SpecPassed(spec)
+	}
+}
+
+func (reporters multiReporter) SpecFailed(spec SpecReport) {
+	for _, r := range reporters {
+		r.SpecFailed(spec)
+	}
+}
+
+func (reporters multiReporter) SpecSkipped(spec SpecReport) {
+	for _, r := range reporters {
+		r.SpecSkipped(spec)
+	}
+}
+
+func (reporters multiReporter) SuiteEnded(summary Summary) {
+	for _, r := range reporters {
+		r.SuiteEnded(summary)
+	}
+}
+
+type textReporter struct {
+	out io.Writer
+	//Describe blocks in the order they were entered, with the durations they had when exited
+	describes []describeDuration
+	//Positions in describes of the Describe blocks currently entered
+	entered []int
+}
+
+type describeDuration struct {
+	description string
+	depth       int
+	duration    time.Duration
+}
+
+// NewTextReporter creates a reporter which writes coloured, human readable results
+func NewTextReporter(out io.Writer) Reporter {
+	return &textReporter{out: out}
+}
+
+func (r *textReporter) SuiteStarted(suite SuiteInfo) {
+	fmt.Fprintln(r.out, "Running tests...")
+
+	if suite.RandomSeed != 0 {
+		fmt.Fprintln(r.out, "Randomized with seed", suite.RandomSeed)
+	}
+}
+
+func (r *textReporter) DescribeEntered(description string) {
+	r.entered = append(r.entered, len(r.describes))
+	r.describes = append(r.describes, describeDuration{description: description, depth: len(r.entered) - 1})
+}
+
+func (r *textReporter) DescribeExited(description string, duration time.Duration) {
+	last := len(r.entered) - 1
+	r.describes[r.entered[last]].duration = duration
+	r.entered = r.entered[:last]
+}
+
+func (r *textReporter) SpecStarted(spec SpecReport) {}
+
+func (r *textReporter) SpecPassed(spec SpecReport) {
+	fmt.Fprintln(r.out, color.GreenString("PASSED:"), spec.FullDescription, "("+formatDuration(spec.Duration)+")")
+}
+
+func (r *textReporter) SpecFailed(spec SpecReport) {
+	fmt.Fprintln(r.out, color.RedString("FAILED:"), spec.FullDescription, "("+formatDuration(spec.Duration)+")")
+	fmt.Fprintln(r.out, color.RedString(spec.Failure))
+
+	for _, location := range specLocations(spec) {
+		fmt.Fprintln(r.out, "\t"+location)
+	}
+}
+
+func (r *textReporter) SpecSkipped(spec SpecReport) {
+	if spec.Pending {
+		fmt.Fprintln(r.out, color.YellowString("PENDING:"), spec.FullDescription)
+		return
+	}
+
+	//Tests filtered out by focus or skip options are only counted, as listing them would bury the tests which ran
+	if spec.SkipReason == filteredSkipReason {
+		return
+	}
+
+	fmt.Fprintln(r.out, color.YellowString("SKIPPED:"), spec.FullDescription, "("+formatDuration(spec.Duration)+")")
+
+	if spec.SkipReason != "" {
+		fmt.Fprintln(r.out, "\t"+spec.SkipReason)
+	}
+}
+
+func (r *textReporter) SuiteEnded(summary Summary) {
+	fmt.Fprintln(r.out, "-----------")
+
+	if summary.Failed == 0 {
+		fmt.Fprintln(r.out, "All", summary.Passed, "tests", color.GreenString("PASSED"))
+	} else {
+		fmt.Fprintln(r.out, summary.Passed, "tests", color.GreenString("PASSED"))
+		fmt.Fprintln(r.out, summary.Failed, "tests", color.RedString("FAILED"))
+	}
+
+	if summary.Skipped > 0 {
+		fmt.Fprintln(r.out, summary.Skipped, "tests", color.YellowString("SKIPPED"))
+	}
+
+	if summary.Pending > 0 {
+		fmt.Fprintln(r.out, summary.Pending, "tests", color.YellowString("PENDING"))
+	}
+
+	fmt.Fprintln(r.out, "Finished in", formatDuration(summary.Duration))
+
+	if len(r.describes) > 0 {
+		fmt.Fprintln(r.out, "-----------")
+		fmt.Fprintln(r.out, "Describe blocks:")
+
+		for _, describe := range r.describes {
+			fmt.Fprintf(r.out, "%10s  %s%s\n", formatDuration(describe.duration), strings.Repeat("  ", describe.depth), describe.description)
+		}
+	}
+
+	if len(summary.SlowSpecs) > 0 {
+		fmt.Fprintln(r.out, "-----------")
+		fmt.Fprintln(r.out, "Slowest tests:")
+
+		for _, spec := range summary.SlowSpecs {
+			fmt.Fprintf(r.out, "%10s  %s (hooks %s)\n", formatDuration(spec.Duration), spec.FullDescription, formatDuration(spec.HookDuration))
+		}
+	}
+}
+
+// Formats a duration rounded to a precision suitable for showing test times
+func formatDuration(duration time.Duration) string {
+	switch {
+	case duration >= time.Second:
+		duration -= duration % time.Millisecond
+	case duration >= time.Millisecond:
+		duration -= duration % time.Microsecond
+	}
+
+	return duration.String()
+}
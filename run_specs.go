@@ -0,0 +1,75 @@
+package testing
+
+import (
+	gotesting "testing"
+	"time"
+)
+
+// Reporter which records the result of each test run by RunSpecs so that it can be reported to its subtest
+type goTestReporter struct {
+	//Reports the result of the last test to finish to its subtest, nil when it passed
+	result func(st *gotesting.T)
+}
+
+func (r *goTestReporter) SuiteStarted(suite SuiteInfo)                              {}
+func (r *goTestReporter) DescribeEntered(description string)                        {}
+func (r *goTestReporter) DescribeExited(description string, duration time.Duration) {}
+func (r *goTestReporter) SpecStarted(spec SpecReport)                               {}
+func (r *goTestReporter) SuiteEnded(summary Summary)                                {}
+
+func (r *goTestReporter) SpecPassed(spec SpecReport) {
+	r.result = nil
+}
+
+func (r *goTestReporter) SpecFailed(spec SpecReport) {
+	r.result = func(st *gotesting.T) {
+		st.Fatal(spec.Failure)
+	}
+}
+
+func (r *goTestReporter) SpecSkipped(spec SpecReport) {
+	r.result = func(st *gotesting.T) {
+		if spec.Pending {
+			st.Skip("Pending")
+		}
+
+		st.Skip(spec.SkipReason)
+	}
+}
+
+// RunSpecs runs the tests declared so far under go test, each one as a subtest of the given test named after its
+// full description. A failed test fails its subtest with t.Fatal and a skipped or pending test skips it with
+// t.Skip. The declared tests are cleared afterwards, so a package can run them from a single TestXxx function:
+//
+//	func TestSpecs(t *testing.T) {
+//		CalculatorTests()
+//		gotest.RunSpecs(t)
+//	}
+//
+// The gotest command runs the tests itself and does not call TestXxx functions which call RunSpecs.
+func RunSpecs(gt *gotesting.T) {
+	reporter := &goTestReporter{}
+	t.reporter = append(multiReporter{reporter}, t.reporters...)
+
+	t.specRunner = func(name string, run func()) {
+		gt.Run(name, func(st *gotesting.T) {
+			reporter.result = nil
+			run()
+
+			if reporter.result != nil {
+				reporter.result(st)
+			}
+		})
+	}
+
+	defer resetSuite()
+
+	runSuite()
+}
+
+// Clears the declared tests and their results so that further tests can be declared and run
+func resetSuite() {
+	reporters := t.reporters
+
+	t = testContext{node: 1, nodes: 1, specRunner: runSpecDirectly, reporters: reporters}
+}
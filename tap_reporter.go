@@ -0,0 +1,81 @@
+package testing
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type tapReporter struct {
+	out   io.Writer
+	count int
+}
+
+// NewTAPReporter creates a reporter which writes results in the Test Anything Protocol version 13 format
+func NewTAPReporter(out io.Writer) Reporter {
+	return &tapReporter{out: out}
+}
+
+// Escapes characters which have a special meaning in a TAP test line
+func tapEscape(description string) string {
+	description = strings.Replace(description, "\\", "\\\\", -1)
+	return strings.Replace(description, "#", "\\#", -1)
+}
+
+func (r *tapReporter) SuiteStarted(suite SuiteInfo) {
+	fmt.Fprintln(r.out, "TAP version 13")
+
+	if suite.RandomSeed != 0 {
+		fmt.Fprintln(r.out, "# Randomized with seed", suite.RandomSeed)
+	}
+}
+
+func (r *tapReporter) DescribeEntered(description string) {
+	fmt.Fprintln(r.out, "#", description)
+}
+
+func (r *tapReporter) DescribeExited(description string, duration time.Duration) {}
+
+func (r *tapReporter) SpecStarted(spec SpecReport) {
+	r.count++
+}
+
+func (r *tapReporter) SpecPassed(spec SpecReport) {
+	fmt.Fprintf(r.out, "ok %d - %s\n", r.count, tapEscape(spec.FullDescription))
+}
+
+func (r *tapReporter) SpecFailed(spec SpecReport) {
+	fmt.Fprintf(r.out, "not ok %d - %s\n", r.count, tapEscape(spec.FullDescription))
+
+	//Failure details go in a YAML diagnostic block
+	fmt.Fprintln(r.out, "  ---")
+	fmt.Fprintf(r.out, "  duration_ms: %.3f\n", spec.Duration.Seconds()*1000)
+	fmt.Fprintln(r.out, "  message: |")
+
+	for _, line := range strings.Split(strings.TrimRight(spec.Failure, "\n"), "\n") {
+		fmt.Fprintln(r.out, "    "+line)
+	}
+
+	if spec.FailureFile != "" {
+		fmt.Fprintf(r.out, "  at: %s:%d\n", displayPath(spec.FailureFile), spec.FailureLine)
+	}
+
+	if spec.File != "" {
+		fmt.Fprintf(r.out, "  declared_at: %s:%d\n", displayPath(spec.File), spec.Line)
+	}
+
+	fmt.Fprintln(r.out, "  ...")
+}
+
+func (r *tapReporter) SpecSkipped(spec SpecReport) {
+	if spec.Pending {
+		fmt.Fprintf(r.out, "not ok %d - %s # TODO pending\n", r.count, tapEscape(spec.FullDescription))
+	} else {
+		fmt.Fprintf(r.out, "ok %d - %s # SKIP %s\n", r.count, tapEscape(spec.FullDescription), spec.SkipReason)
+	}
+}
+
+func (r *tapReporter) SuiteEnded(summary Summary) {
+	fmt.Fprintf(r.out, "1..%d\n", r.count)
+}
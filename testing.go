@@ -1,10 +1,18 @@
 package testing
 
 import (
+	"flag"
 	"fmt"
-	"github.com/fatih/color"
+	"io"
+	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 type blockType uint
@@ -20,49 +28,180 @@ type testContext struct {
 	currentRunningTest string
 	passed             int
 	failed             int
+	skipped            int
+	pending            int
+	beforeSuites       []func()
+	afterSuites        []func()
+	hasFocusedBlocks   bool
+	focusRegex         *regexp.Regexp
+	skipRegex          *regexp.Regexp
+	reporters          []Reporter
+	reporter           Reporter
+	slowThreshold      time.Duration
+	slowCount          int
+	timeout            time.Duration
+	randomize          bool
+	randomizeAll       bool
+	seed               int64
+	//Set when running as one of several worker processes: the number of this node (starting at 1) and of all nodes
+	node    int
+	nodes   int
+	syncDir string
+	//Node result files to merge instead of running tests
+	mergePaths []string
+	//Index of the test currently being run, used to order results from several nodes
+	currentIndex int
+	//Reports of all tests which were run, used to find the slowest tests
+	ranSpecs []SpecReport
+	//Format to list the tests in instead of running them, and where to write the list
+	listFormat string
+	listOutput io.Writer
+	//Runs and reports each test given its full description. RunSpecs replaces it to run each test as a subtest.
+	specRunner func(name string, run func())
+	//Package the blocks being declared belong to, set by SetPackage
+	currentPackage *testPackage
+	//Directory of the package whose tests or hooks are running
+	packageDir string
+}
+
+// A package the tests were declared in, whose directory they are run in as with go test
+type testPackage struct {
+	importPath string
+	dir        string
 }
 
 type block struct {
 	blockType   blockType
 	description string
+	focused     bool
+	pending     bool
 	parent      *block
 	children    []*block
 	beforeEachs []func()
 	afterEachs  []func()
+	beforeAlls  []func()
+	afterAlls   []func()
 	body        func()
+	timeout     time.Duration
+	//File and line the block was declared at
+	file string
+	line int
+	//Position of the test among all tests to run and the node it is run on
+	index int
+	node  int
+	//Package the block was declared in, nil unless set with SetPackage
+	pkg *testPackage
+}
+
+// State inherited by a block from its enclosing Describe blocks while running tests
+type runState struct {
+	description string
+	containers  []string
+	focused     bool
+	pending     bool
+	skipped     bool
+	skipReason  string
+	//Set when a BeforeAll or BeforeSuite block failed. Affected tests are reported as failed without being run.
+	failure string
+	//Package the innermost block was declared in
+	pkg *testPackage
 }
 
-var t = testContext{currentBlock: nil}
+// Returns the import path of the package of the current block, or an empty string if it was not set
+func (s runState) packagePath() string {
+	if s.pkg == nil {
+		return ""
+	}
+
+	return s.pkg.importPath
+}
+
+var t = testContext{currentBlock: nil, node: 1, nodes: 1, specRunner: runSpecDirectly}
+
+func runSpecDirectly(name string, run func()) {
+	run()
+}
 
 func (t *testContext) addBlock(block *block) {
+	block.pkg = t.currentPackage
+
+	if block.focused {
+		t.hasFocusedBlocks = true
+	}
+
 	if t.currentBlock == nil {
 		t.topLevelBlocks = append(t.topLevelBlocks, block)
 	} else {
-		//Run parent BeforeEachs before child BeforeEachs
-		block.beforeEachs = append(t.currentBlock.beforeEachs, block.beforeEachs...)
-		//Run child AfterEachs before parent AfterEachs
-		block.afterEachs = append(block.afterEachs, t.currentBlock.afterEachs...)
 		t.currentBlock.children = append(t.currentBlock.children, block)
 		block.parent = t.currentBlock
 	}
 }
 
-func Describe(desc string, processChildBlocks func()) {
-	b := block{blockType: describe, description: desc, parent: t.currentBlock}
+// SetPackage sets the package the tests declared after it belong to. They are run with the directory of the
+// package as the working directory, as with go test, so that they can read files such as testdata relative to it.
+// The gotest command calls it before declaring the tests of each package.
+func SetPackage(importPath, dir string) {
+	t.currentPackage = &testPackage{importPath: importPath, dir: dir}
+}
+
+// Changes the working directory to the directory of a package before running its tests or hooks
+func enterPackage(pkg *testPackage) {
+	if pkg == nil {
+		return
+	}
+
+	if err := os.Chdir(pkg.dir); err != nil {
+		panic(fmt.Sprintf("Error changing to package directory %s : %s", pkg.dir, err))
+	}
+
+	t.packageDir = pkg.dir
+}
+
+// Wraps a suite hook so that it runs in the directory of the package it was declared in
+func inPackage(pkg *testPackage, body func()) func() {
+	if pkg == nil {
+		return body
+	}
+
+	return func() {
+		enterPackage(pkg)
+		body()
+	}
+}
+
+// Panic value used by Skip to stop the currently running test
+type skipSignal struct {
+	reason string
+}
+
+// Returns the file and line of the test code which declared a block, given the number of frames between it and
+// the caller of this function
+func declarationSite(skip int) (string, int) {
+	_, file, line, ok := runtime.Caller(skip + 2)
+
+	if !ok {
+		return "???", 1
+	}
+
+	return file, line
+}
+
+func describeBlock(desc string, focused, pending bool, processChildBlocks func()) {
+	b := block{blockType: describe, description: desc, parent: t.currentBlock, focused: focused, pending: pending}
+	b.file, b.line = declarationSite(1)
 
 	t.addBlock(&b)
 	t.currentBlock = &b
 
 	processChildBlocks()
 
-	//Reset current block after processing top level block
-	if b.parent == nil {
-		t.currentBlock = nil
-	}
+	//Restore the enclosing block so that following blocks are added to it rather than to this one
+	t.currentBlock = b.parent
 }
 
-func It(desc string, body func()) {
-	b := block{blockType: it, description: desc, parent: t.currentBlock, body: body}
+func itBlock(desc string, focused, pending bool, timeout time.Duration, body func()) {
+	b := block{blockType: it, description: desc, parent: t.currentBlock, body: body, focused: focused, pending: pending, timeout: timeout}
+	b.file, b.line = declarationSite(1)
 
 	t.addBlock(&b)
 
@@ -72,8 +211,59 @@ func It(desc string, body func()) {
 	}
 }
 
+func Describe(desc string, processChildBlocks func()) {
+	describeBlock(desc, false, false, processChildBlocks)
+}
+
+// FDescribe is a focused Describe. When any focused blocks exist only the tests inside focused blocks are run.
+func FDescribe(desc string, processChildBlocks func()) {
+	describeBlock(desc, true, false, processChildBlocks)
+}
+
+// XDescribe is a pending Describe. All of the tests inside it are reported as pending and are not run.
+func XDescribe(desc string, processChildBlocks func()) {
+	describeBlock(desc, false, true, processChildBlocks)
+}
+
+// It declares a test. An It with a nil body is reported as pending.
+func It(desc string, body func()) {
+	itBlock(desc, false, false, 0, body)
+}
+
+// FIt is a focused It. When any focused blocks exist only the tests inside focused blocks are run.
+func FIt(desc string, body func()) {
+	itBlock(desc, true, false, 0, body)
+}
+
+// XIt is a pending It. The test is reported as pending and is not run.
+func XIt(desc string, body func()) {
+	itBlock(desc, false, true, 0, body)
+}
+
+// ItWithTimeout declares a test which fails if it, along with its BeforeEach and AfterEach blocks, takes
+// longer than the given timeout. It overrides the --timeout option.
+func ItWithTimeout(desc string, timeout time.Duration, body func()) {
+	itBlock(desc, false, false, timeout, body)
+}
+
+// FItWithTimeout is a focused ItWithTimeout
+func FItWithTimeout(desc string, timeout time.Duration, body func()) {
+	itBlock(desc, true, false, timeout, body)
+}
+
+// XItWithTimeout is a pending ItWithTimeout
+func XItWithTimeout(desc string, timeout time.Duration, body func()) {
+	itBlock(desc, false, true, timeout, body)
+}
+
+// Skip stops the currently running test and reports it as skipped. It may be called from inside an It
+// or a BeforeEach block.
+func Skip(reason string) {
+	panic(skipSignal{reason: reason})
+}
+
 func BeforeEach(body func()) {
-	if t.currentBlock.blockType == describe {
+	if t.currentBlock != nil && t.currentBlock.blockType == describe {
 		t.currentBlock.beforeEachs = append(t.currentBlock.beforeEachs, body)
 	} else {
 		panic("BeforeEach may only be applied inside Describe blocks")
@@ -81,77 +271,767 @@ func BeforeEach(body func()) {
 }
 
 func AfterEach(body func()) {
-	if t.currentBlock.blockType == describe {
+	if t.currentBlock != nil && t.currentBlock.blockType == describe {
 		t.currentBlock.afterEachs = append(t.currentBlock.afterEachs, body)
 	} else {
 		panic("AfterEach may only be applied inside Describe blocks")
 	}
 }
 
-func runTest(body func(), testName string) {
+// BeforeAll blocks are called once before the first test in the current Describe block (including any child
+// Describe blocks). If a BeforeAll block fails every test in the Describe block is reported as failed.
+func BeforeAll(body func()) {
+	if t.currentBlock != nil && t.currentBlock.blockType == describe {
+		t.currentBlock.beforeAlls = append(t.currentBlock.beforeAlls, body)
+	} else {
+		panic("BeforeAll may only be applied inside Describe blocks")
+	}
+}
+
+// AfterAll blocks are called once after the last test in the current Describe block (including any child
+// Describe blocks).
+func AfterAll(body func()) {
+	if t.currentBlock != nil && t.currentBlock.blockType == describe {
+		t.currentBlock.afterAlls = append(t.currentBlock.afterAlls, body)
+	} else {
+		panic("AfterAll may only be applied inside Describe blocks")
+	}
+}
+
+// BeforeSuite blocks are called once before any tests are run. If a BeforeSuite block fails every test is
+// reported as failed.
+func BeforeSuite(body func()) {
+	if t.currentBlock == nil {
+		t.beforeSuites = append(t.beforeSuites, inPackage(t.currentPackage, body))
+	} else {
+		panic("BeforeSuite may not be applied inside Describe blocks")
+	}
+}
+
+// AfterSuite blocks are called once after all tests have been run.
+func AfterSuite(body func()) {
+	if t.currentBlock == nil {
+		t.afterSuites = append(t.afterSuites, inPackage(t.currentPackage, body))
+	} else {
+		panic("AfterSuite may not be applied inside Describe blocks")
+	}
+}
+
+func reportFailed(spec SpecReport, errs ...interface{}) {
+	messages := make([]string, len(errs))
+
+	for i, err := range errs {
+		messages[i] = fmt.Sprint(err)
+	}
+
+	spec.Failure = strings.Join(messages, "\n")
+	t.reporter.SpecFailed(spec)
+	t.failed++
+}
+
+// Determines whether the test with the given full description should be run given the focused blocks
+// and the --focus and --skip filters
+func (t *testContext) shouldRun(testName string, focused bool) bool {
+	if t.hasFocusedBlocks && !focused {
+		return false
+	}
+
+	if t.focusRegex != nil && !t.focusRegex.MatchString(testName) {
+		return false
+	}
+
+	if t.skipRegex != nil && t.skipRegex.MatchString(testName) {
+		return false
+	}
+
+	return true
+}
+
+func reportSkipped(spec SpecReport, reason string) {
+	spec.SkipReason = reason
+	t.reporter.SpecSkipped(spec)
+	t.skipped++
+}
+
+func reportPassed(spec SpecReport) {
+	t.reporter.SpecPassed(spec)
+	t.passed++
+}
+
+// Failure of a function which panicked, along with where the test code panicked
+type panicFailure struct {
+	value interface{}
+	//Stack of the panicking goroutine, which is searched for the test code once the package under test is known
+	stack []uintptr
+}
+
+func (f panicFailure) String() string {
+	return fmt.Sprint(f.value)
+}
+
+// Reports whether a stack frame is in a test file or in the package under test, in the given directory, rather than
+// in a library or gotest itself. Frames are matched by directory as gotest's own packages are copied to be tested.
+func isTestCodeFrame(frame runtime.Frame, packageDir string) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return true
+	}
+
+	_, file, _, _ := runtime.Caller(0)
+	gotestDir := filepath.Dir(file)
+	dir := filepath.Dir(filepath.FromSlash(frame.File))
+
+	return dir == filepath.Clean(packageDir) && dir != gotestDir && dir != filepath.Join(gotestDir, "assert")
+}
+
+// Returns the file and line of the innermost frame of test code on the stack of a panicking goroutine. The files of
+// the copied test packages are mapped back to the original files by the line directives gotest adds to them.
+func (f panicFailure) site(packageDir string) (string, int) {
+	frames := runtime.CallersFrames(f.stack)
+
+	for {
+		frame, more := frames.Next()
+
+		if isTestCodeFrame(frame, packageDir) {
+			return frame.File, frame.Line
+		}
+
+		if !more {
+			return "", 0
+		}
+	}
+}
+
+// Runs the given function and returns the value of any panic. Panics other than calls to Skip are returned as a
+// panicFailure recording where they happened.
+func capture(f func()) (err interface{}) {
 	defer func() {
-		err := recover()
+		err = recover()
 
-		if err != nil {
-			fmt.Println(color.RedString("FAILED:"), testName)
-			errStr, ok := err.(string)
-			if ok {
-				fmt.Println(color.RedString(errStr))
-			} else {
-				fmt.Println(err)
-			}
+		_, skipped := err.(skipSignal)
+		_, captured := err.(panicFailure)
 
-			t.failed++
+		//A failure which was captured before and panicked with again keeps where it originally happened
+		if err != nil && !skipped && !captured {
+			failure := panicFailure{value: err, stack: make([]uintptr, 64)}
+			failure.stack = failure.stack[:runtime.Callers(2, failure.stack)]
+			err = failure
 		}
 	}()
 
-	body()
+	f()
 
-	fmt.Println(color.GreenString("PASSED:"), testName)
+	return nil
+}
 
-	t.passed++
+// Records where a test failed in its report if the failure happened in the test code
+func recordFailureSite(spec *SpecReport, err interface{}) {
+	packageDir := t.packageDir
+
+	//Tests run by go test rather than by the gotest command run in the directory of their package
+	if packageDir == "" {
+		packageDir = startDir
+	}
+
+	if failure, ok := err.(panicFailure); ok && spec.FailureFile == "" {
+		spec.FailureFile, spec.FailureLine = failure.site(packageDir)
+	}
+}
+
+// Returns the name of a BeforeAll, AfterAll, BeforeSuite or AfterSuite block used in failure messages
+func hookName(hookType string, state runState) string {
+	if state.description == "" {
+		return hookType
+	}
+
+	return hookType + " in " + state.description
 }
 
-func (b block) run(testDescriptionPrefix string) {
-	testName := strings.TrimSpace(testDescriptionPrefix + " " + b.description)
+// Failure of a function which did not return within its timeout
+type timeoutFailure struct {
+	timeout time.Duration
+	stacks  string
+}
+
+func (f timeoutFailure) String() string {
+	return fmt.Sprintf("Timed out after %s\n\nGoroutine dump:\n%s", f.timeout, f.stacks)
+}
+
+// Returns the stack traces of all goroutines
+func goroutineDump() string {
+	buf := make([]byte, 1<<16)
+
+	for {
+		n := runtime.Stack(buf, true)
+
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// Runs the given function like capture but gives up waiting for it after the given timeout, returning a
+// timeoutFailure. The function is left running in the background so that the remaining tests can be run.
+func captureWithTimeout(f func(), timeout time.Duration) interface{} {
+	if timeout <= 0 {
+		return capture(f)
+	}
+
+	done := make(chan interface{}, 1)
+
+	go func() {
+		done <- capture(f)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return timeoutFailure{timeout: timeout, stacks: goroutineDump()}
+	}
+}
+
+// Runs BeforeAll or BeforeSuite blocks. A failure or call to Skip is recorded in the returned state so that
+// it is reported against every test the blocks apply to.
+func runSetupHooks(state runState, hooks []func(), hookType string, timeout time.Duration) runState {
+	err := captureWithTimeout(func() {
+		for _, hook := range hooks {
+			hook()
+		}
+	}, timeout)
+
+	if signal, ok := err.(skipSignal); ok {
+		state.skipped = true
+		state.skipReason = signal.reason
+	} else if err != nil {
+		state.failure = fmt.Sprintf("%s failed:\n%v", hookName(hookType, state), err)
+	}
+
+	return state
+}
+
+// Runs AfterAll or AfterSuite blocks. A failure is reported on its own as it happens after the affected tests
+// have already been reported.
+func runTeardownHooks(state runState, hooks []func(), hookType string, timeout time.Duration) {
+	start := time.Now()
+
+	err := captureWithTimeout(func() {
+		for _, hook := range hooks {
+			hook()
+		}
+	}, timeout)
+
+	if _, ok := err.(skipSignal); !ok && err != nil {
+		spec := SpecReport{FullDescription: hookName(hookType, state), Containers: state.containers, Description: hookType,
+			Package: state.packagePath()}
+		recordFailureSite(&spec, err)
+		spec.Duration = time.Since(start)
+		spec.HookDuration = spec.Duration
+
+		t.specRunner(spec.FullDescription, func() {
+			t.reporter.SpecStarted(spec)
+			reportFailed(spec, err)
+		})
+	}
+}
+
+func hookFailure(hookName string, owner *block, err interface{}) string {
+	return fmt.Sprintf("%s in %s failed:\n%v", hookName, owner.fullDescription(), err)
+}
+
+func (b *block) fullDescription() string {
+	if b.parent == nil {
+		return b.description
+	}
+
+	return strings.TrimSpace(b.parent.fullDescription() + " " + b.description)
+}
+
+// Returns the Describe blocks enclosing a block, outermost first
+func (b *block) enclosingDescribes() []*block {
+	if b.parent == nil {
+		return nil
+	}
+
+	return append(b.parent.enclosingDescribes(), b.parent)
+}
+
+// Returns the state of a block given the state inherited from its enclosing Describe blocks
+func (b block) enter(state runState) runState {
+	state.description = strings.TrimSpace(state.description + " " + b.description)
+	state.focused = state.focused || b.focused
+	state.pending = state.pending || b.pending || (b.blockType == it && b.body == nil)
+
+	if b.pkg != nil {
+		state.pkg = b.pkg
+	}
 
 	if b.blockType == describe {
-		for _, childBlock := range b.children {
+		state.containers = append(append([]string{}, state.containers...), b.description)
+	}
 
-			if childBlock.blockType == it {
-				for _, before := range b.beforeEachs {
-					before()
+	return state
+}
+
+// Calls visit with every It block in declaration order along with its state
+func walkTests(blocks []*block, state runState, visit func(b *block, state runState)) {
+	for _, b := range blocks {
+		blockState := b.enter(state)
+
+		if b.blockType == it {
+			visit(b, blockState)
+		} else {
+			walkTests(b.children, blockState, visit)
+		}
+	}
+}
+
+// Determines whether a test is run by this process when tests are split across several nodes
+func (t *testContext) isOnThisNode(b *block) bool {
+	return t.nodes <= 1 || b.node == t.node
+}
+
+// Counts the tests inside a Describe block which will be run, or reported as pending if includePending is set,
+// given its state
+func (b block) countTests(state runState, includePending bool) int {
+	return countTests(b.children, state, includePending)
+}
+
+func countTests(blocks []*block, state runState, includePending bool) int {
+	count := 0
+
+	walkTests(blocks, state, func(b *block, state runState) {
+		if (includePending || !state.pending) && t.shouldRun(state.description, state.focused) && t.isOnThisNode(b) {
+			count++
+		}
+	})
+
+	return count
+}
+
+// Runs a test, calling the BeforeEach and AfterEach blocks of its enclosing Describe blocks. A panic in any
+// of them fails the test.
+func (b block) runIt(state runState) {
+	state = b.enter(state)
+	spec := SpecReport{FullDescription: state.description, Containers: state.containers, Description: b.description,
+		Package: state.packagePath()}
+	spec.File, spec.Line = b.file, b.line
+
+	if !t.shouldRun(spec.FullDescription, state.focused) {
+		//When tests are split across several nodes the first node reports the tests which are filtered out
+		if t.node == 1 {
+			t.currentIndex = b.index
+			t.reporter.SpecStarted(spec)
+			reportSkipped(spec, filteredSkipReason)
+		}
+
+		return
+	}
+
+	if !t.isOnThisNode(&b) {
+		return
+	}
+
+	t.specRunner(spec.FullDescription, func() {
+		b.execute(state, spec)
+	})
+}
+
+// Runs a test which has been selected to run and reports the result
+func (b block) execute(state runState, spec SpecReport) {
+	t.currentIndex = b.index
+	enterPackage(b.pkg)
+	t.reporter.SpecStarted(spec)
+
+	if state.pending {
+		spec.Pending = true
+		t.reporter.SpecSkipped(spec)
+		t.pending++
+		return
+	}
+
+	if state.failure != "" {
+		reportFailed(spec, state.failure)
+		return
+	}
+
+	if state.skipped {
+		reportSkipped(spec, state.skipReason)
+		return
+	}
+
+	enclosing := b.enclosingDescribes()
+
+	var errs []interface{}
+	var failedLevel *block
+	var bodyStart, bodyEnd time.Time
+
+	timeout := b.timeout
+
+	if timeout <= 0 {
+		timeout = t.timeout
+	}
+
+	start := time.Now()
+
+	//BeforeEach blocks are called from the outermost Describe block inwards, stopping at the first failure
+	err := captureWithTimeout(func() {
+		for _, d := range enclosing {
+			failedLevel = d
+
+			for _, before := range d.beforeEachs {
+				before()
+			}
+		}
+
+		failedLevel = nil
+
+		bodyStart = time.Now()
+		defer func() {
+			bodyEnd = time.Now()
+		}()
+
+		b.body()
+	}, timeout)
+
+	signal, skipped := err.(skipSignal)
+	_, timedOut := err.(timeoutFailure)
+
+	//A test which timed out is still running so the state it shares with this function must not be read
+	if err != nil && !skipped {
+		recordFailureSite(&spec, err)
+
+		if !timedOut && failedLevel != nil {
+			err = hookFailure("BeforeEach", failedLevel, err)
+		}
+
+		errs = append(errs, err)
+	}
+
+	//AfterEach blocks are called from the innermost Describe block outwards, sharing what is left of the timeout.
+	//When none is left they share the timeout once more so that cleanup happens, but after one of them times out
+	//the rest are not called.
+	deadline := start.Add(timeout)
+
+	if !time.Now().Before(deadline) {
+		deadline = time.Now().Add(timeout)
+	}
+
+	afterTimedOut := false
+
+	for i := len(enclosing) - 1; i >= 0 && !afterTimedOut; i-- {
+		for _, after := range enclosing[i].afterEachs {
+			remaining := time.Duration(0)
+
+			//A spent budget must not be taken as no timeout at all
+			if timeout > 0 {
+				remaining = time.Until(deadline)
+
+				if remaining <= 0 {
+					remaining = time.Nanosecond
 				}
 			}
 
-			childBlock.run(testName)
+			err := captureWithTimeout(after, remaining)
+
+			if failure, ok := err.(timeoutFailure); ok {
+				failure.timeout = timeout
+				err = failure
+				afterTimedOut = true
+			}
 
-			if childBlock.blockType == it {
-				for _, after := range b.afterEachs {
-					after()
+			if err != nil {
+				if _, ok := err.(skipSignal); !ok {
+					recordFailureSite(&spec, err)
+					errs = append(errs, hookFailure("AfterEach", enclosing[i], err))
 				}
 			}
+
+			if afterTimedOut {
+				break
+			}
 		}
+	}
+
+	spec.Duration = time.Since(start)
+	spec.HookDuration = spec.Duration
+
+	if !timedOut {
+		spec.HookDuration -= bodyEnd.Sub(bodyStart)
+	}
+	t.ranSpecs = append(t.ranSpecs, spec)
+
+	if len(errs) > 0 {
+		reportFailed(spec, errs...)
+	} else if skipped {
+		reportSkipped(spec, signal.reason)
 	} else {
-		runTest(b.body, testName)
+		reportPassed(spec)
 	}
 }
 
+func (b block) run(state runState) {
+	if b.blockType == it {
+		b.runIt(state)
+		return
+	}
+
+	state = b.enter(state)
+
+	//Describe blocks without any tests to report are left out of the results
+	reported := b.countTests(state, true) > 0
+
+	if reported {
+		t.reporter.DescribeEntered(b.description)
+	}
+
+	start := time.Now()
+
+	//BeforeAll and AfterAll blocks are only called if there is at least one test to run
+	runAllHooks := (len(b.beforeAlls) > 0 || len(b.afterAlls) > 0) &&
+		state.failure == "" && !state.skipped && b.countTests(state, false) > 0
+
+	if runAllHooks {
+		enterPackage(b.pkg)
+		state = runSetupHooks(state, b.beforeAlls, "BeforeAll", t.timeout)
+	}
+
+	for _, childBlock := range b.children {
+		childBlock.run(state)
+	}
+
+	if runAllHooks {
+		enterPackage(b.pkg)
+		runTeardownHooks(state, b.afterAlls, "AfterAll", t.timeout)
+	}
+
+	if reported {
+		t.reporter.DescribeExited(b.description, time.Since(start))
+	}
+}
+
+func compileFilter(name, expr string) *regexp.Regexp {
+	if expr == "" {
+		return nil
+	}
+
+	regex, err := regexp.Compile(expr)
+
+	if err != nil {
+		fmt.Println("Invalid", name, "regular expression:", err)
+		os.Exit(1)
+	}
+
+	return regex
+}
+
+// Parses the options passed through from the gotest command to the test binary
+func parseFlags() (output io.Closer) {
+	flags := flag.NewFlagSet("gotest", flag.ExitOnError)
+	focus := flags.String("focus", "", "only run tests whose full description matches this regular expression")
+	skip := flags.String("skip", "", "skip tests whose full description matches this regular expression")
+	reporterName := flags.String("reporter", "text", "format of the test results: text, junit, tap or json")
+	outputPath := flags.String("output", "", "file to write the test results to instead of stdout")
+	flags.DurationVar(&t.slowThreshold, "slow-threshold", 0, "list the tests which take longer than this duration")
+	flags.DurationVar(&t.timeout, "timeout", 0, "fail tests which take longer than this duration")
+	flags.IntVar(&t.slowCount, "slow-count", 10, "maximum number of slow tests to list")
+	flags.BoolVar(&t.randomize, "randomize", false, "run top level Describe and It blocks in a random order")
+	flags.BoolVar(&t.randomizeAll, "randomize-all", false, "run the blocks at every level in a random order")
+	flags.Int64Var(&t.seed, "seed", 0, "seed used to randomize the order of the tests")
+	flags.IntVar(&t.node, "node", 1, "number of this node when tests are split across several processes")
+	flags.IntVar(&t.nodes, "nodes", 1, "number of processes the tests are split across")
+	nodeOutputPath := flags.String("node-output", "", "file to write the results of this node to for merging")
+	flags.StringVar(&t.syncDir, "sync-dir", "", "directory used to synchronize suite hooks between nodes")
+	mergePaths := flags.String("merge", "", "comma separated node result files to merge and report instead of running tests")
+	flags.StringVar(&t.listFormat, "list", "", "list the tests without running them, as a tree or as json")
+
+	flags.Parse(os.Args[1:])
+
+	t.focusRegex = compileFilter("focus", *focus)
+	t.skipRegex = compileFilter("skip", *skip)
+
+	if t.randomizeAll {
+		t.randomize = true
+	}
+
+	if t.randomize && t.seed == 0 {
+		t.seed = time.Now().UnixNano()
+	}
+
+	if *mergePaths != "" {
+		t.mergePaths = strings.Split(*mergePaths, ",")
+	}
+
+	//Nodes only record their results, they are reported once the results of all nodes are merged
+	if *nodeOutputPath != "" {
+		file, err := os.Create(*nodeOutputPath)
+
+		if err != nil {
+			fmt.Println("Error creating node output file:", err)
+			os.Exit(1)
+		}
+
+		t.reporter = newNodeReporter(file)
+
+		return file
+	}
+
+	var out io.Writer = os.Stdout
+
+	if *outputPath != "" {
+		file, err := os.Create(*outputPath)
+
+		if err != nil {
+			fmt.Println("Error creating output file:", err)
+			os.Exit(1)
+		}
+
+		out = file
+		output = file
+	}
+
+	if t.listFormat != "" {
+		t.listOutput = out
+		return output
+	}
+
+	reporter, err := newReporter(*reporterName, out)
+
+	if err != nil {
+		fmt.Println("Invalid reporter:", err)
+		os.Exit(1)
+	}
+
+	reporters := multiReporter{reporter}
+
+	//Keep showing results on the console when writing a machine readable report to a file
+	if *outputPath != "" && *reporterName != "text" {
+		reporters = append(reporters, NewTextReporter(os.Stdout))
+	}
+
+	t.reporter = append(reporters, t.reporters...)
+
+	return output
+}
+
+// Shuffles blocks in place, along with the children of every Describe block if all is set. The same seed always
+// gives the same order so that a failing run can be repeated.
+func shuffleBlocks(blocks []*block, random *rand.Rand, all bool) {
+	shuffled := make([]*block, len(blocks))
+
+	for i, j := range random.Perm(len(blocks)) {
+		shuffled[i] = blocks[j]
+	}
+
+	copy(blocks, shuffled)
+
+	if all {
+		for _, b := range blocks {
+			shuffleBlocks(b.children, random, all)
+		}
+	}
+}
+
+func suiteInfo() SuiteInfo {
+	info := SuiteInfo{SpecCount: countTests(t.topLevelBlocks, runState{}, true)}
+
+	if t.randomize {
+		info.RandomSeed = t.seed
+	}
+
+	return info
+}
+
+// Returns the tests which took longer than the --slow-threshold option, slowest first
+func slowSpecs() []SpecReport {
+	if t.slowThreshold <= 0 {
+		return nil
+	}
+
+	var slow []SpecReport
+
+	for _, spec := range t.ranSpecs {
+		if spec.Duration >= t.slowThreshold {
+			slow = append(slow, spec)
+		}
+	}
+
+	sort.SliceStable(slow, func(i, j int) bool {
+		return slow[i].Duration > slow[j].Duration
+	})
+
+	if len(slow) > t.slowCount {
+		slow = slow[:t.slowCount]
+	}
+
+	return slow
+}
+
 func RunTests() {
-	fmt.Println("Running tests...")
+	if key := os.Getenv(goTestEnv); key != "" {
+		runGoTestProcess(key)
+	}
 
-	for _, b := range t.topLevelBlocks {
-		b.run("")
+	output := parseFlags()
+
+	if t.mergePaths != nil {
+		mergeNodeResults(output)
 	}
 
-	fmt.Println("-----------")
+	if t.listFormat != "" {
+		listSpecs(t.listOutput, t.listFormat)
+
+		if output != nil {
+			output.Close()
+		}
+
+		os.Exit(0)
+	}
+
+	runSuite()
+
+	if output != nil {
+		output.Close()
+	}
 
 	if t.failed == 0 {
-		fmt.Println("All", t.passed, "tests", color.GreenString("PASSED"))
 		os.Exit(0)
 	} else {
-		fmt.Println(t.passed, "tests", color.GreenString("PASSED"))
-		fmt.Println(t.failed, "tests", color.RedString("FAILED"))
 		os.Exit(1)
 	}
 }
+
+// Runs the suite hooks and the tests in the order they are to be run and reports them to the reporter
+func runSuite() {
+	suiteState := runState{}
+
+	if t.randomize {
+		shuffleBlocks(t.topLevelBlocks, rand.New(rand.NewSource(t.seed)), t.randomizeAll)
+	}
+
+	assignNodes(suiteState)
+
+	t.reporter.SuiteStarted(suiteInfo())
+
+	start := time.Now()
+
+	//Suite hooks are not subject to the timeout as they may wait for other nodes
+	state := runSetupHooks(suiteState, t.beforeSuites, "BeforeSuite", 0)
+
+	for _, b := range t.topLevelBlocks {
+		b.run(state)
+	}
+
+	t.currentIndex = math.MaxInt32
+	runTeardownHooks(suiteState, t.afterSuites, "AfterSuite", 0)
+
+	summary := Summary{Passed: t.passed, Failed: t.failed, Skipped: t.skipped, Pending: t.pending}
+	summary.Duration = time.Since(start)
+	summary.SlowSpecs = slowSpecs()
+
+	t.reporter.SuiteEnded(summary)
+}